@@ -1,6 +1,7 @@
 package mc
 
 import (
+	"cmp"
 	"context"
 	"encoding/binary"
 	"errors"
@@ -17,22 +18,45 @@ const MagicValue = 0x42
 var endian = binary.BigEndian
 
 func New(o *Options) (_ *Client, err error) {
-	o.setDefaults()
+	customPick := o.PickServer != nil
+	if o.Discovery != nil && len(o.Addrs) == 0 && len(o.Servers) == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(o.DialTimeout, DefaultTimeout))
+		o.Servers, err = discover(ctx, o.Discovery)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := o.setDefaults(); err != nil {
+		return nil, err
+	}
 	if len(o.Addrs) == 0 {
 		return nil, ErrNoServers
 	}
 	var (
 		cli = &Client{
-			opts: o,
+			opts:       o,
+			customPick: customPick,
 			pool: pool{
-				idle:            make(map[string]chan *conn),
 				dialTimeout:     o.DialTimeout,
 				connMaxLifetime: o.ConnMaxLifetime,
+				connMaxIdleTime: o.ConnMaxIdleTime,
+				health:          newHealth(o),
+				done:            make(chan struct{}),
 			},
 		}
+		s = &servers{
+			pick:  o.PickServer,
+			addrs: make(map[string]*addrPool, len(o.Addrs)),
+		}
 	)
-	for _, s := range o.Addrs {
-		cli.pool.idle[s] = make(chan *conn, o.MaxIdleConnsPerAddr)
+	for _, addr := range o.Addrs {
+		s.addrs[addr] = newAddrPool(o)
+	}
+	cli.pool.servers.Store(s)
+	clock.Start()
+	if o.Discovery != nil {
+		go cli.refreshServers(o.Servers)
 	}
 	return cli, nil
 }
@@ -40,17 +64,196 @@ func New(o *Options) (_ *Client, err error) {
 type Client struct {
 	pool pool
 	opts *Options
+	// customPick is set if PickServer is not built by setDefaults.
+	customPick bool
+	coalescer  coalescer
+}
+
+// Close closes idle connections, connections in use are closed once their request
+// completes. Calls made after Close return ErrClientClosed. Close is safe to call
+// concurrently with other calls and more than once.
+func (c *Client) Close() error {
+	if c.pool.close() {
+		clock.Stop()
+	}
+	return nil
 }
 
 func (c *Client) Get(ctx context.Context, key string) (*Item, error) {
-	data, extra, cas, err := c.request(ctx, protocol.Get, key, nil, nil, 0)
+	if !c.intercepted() {
+		return c.get(ctx, key)
+	}
+	res, err := c.intercept(ctx, &Operation{Name: "Get", Opcode: protocol.Get, Keys: []string{key}}, func(ctx context.Context, op *Operation) (*Result, error) {
+		i, err := c.get(ctx, op.Keys[0])
+		return &Result{Item: i}, err
+	})
+	return res.Item, err
+}
+
+func (c *Client) get(ctx context.Context, key string) (i *Item, err error) {
+	ctx, span := c.startSpan(ctx, "", protocol.Get)
+	if span != nil {
+		defer func() {
+			span.SetAttribute(AttrHit, err == nil)
+			if i != nil {
+				span.SetAttribute(AttrValueSize, len(i.Value))
+			}
+			span.End(err)
+		}()
+	}
+	data, extra, cas, err := c.read(ctx, protocol.Get, key, nil, nil)
 	if err != nil {
 		return nil, err
 	}
+	i, _, err = c.unwrap(ctx, key, data, extra, cas)
+	return i, err
+}
 
+// GetInto appends the value of the key to dst and returns the extended buffer,
+// pass dst[:0] to reuse its memory. Unlike Get it doesn't allocate for values
+// stored without an envelope.
+func (c *Client) GetInto(ctx context.Context, key string, dst []byte) ([]byte, error) {
+	if !c.intercepted() {
+		return c.getInto(ctx, key, dst)
+	}
+	res, err := c.intercept(ctx, &Operation{Name: "GetInto", Opcode: protocol.Get, Keys: []string{key}}, func(ctx context.Context, op *Operation) (*Result, error) {
+		value, err := c.getInto(ctx, op.Keys[0], dst)
+		return &Result{Item: &Item{Key: op.Keys[0], Value: value}}, err
+	})
+	if res.Item == nil {
+		return dst, err
+	}
+	return res.Item.Value, err
+}
+
+func (c *Client) getInto(ctx context.Context, key string, dst []byte) (value []byte, err error) {
+	ctx, span := c.startSpan(ctx, "", protocol.Get)
+	if span != nil {
+		defer func() {
+			span.SetAttribute(AttrHit, err == nil)
+			span.SetAttribute(AttrValueSize, len(value)-len(dst))
+			span.End(err)
+		}()
+	}
+	if dst == nil {
+		// nil means no buffer to request.
+		dst = []byte{}
+	}
+	data, extra, cas, err := c.read(ctx, protocol.Get, key, nil, dst)
+	if err != nil {
+		return dst, err
+	}
+	if len(extra) >= 4 && extra[0] == MagicValue {
+		i, _, err := c.unwrap(ctx, key, data[len(dst):], extra, cas)
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, i.Value...), nil
+	}
+	return data, nil
+}
+
+// GetAndTouch gets the item and sets its expiration time to exp seconds in one round-trip.
+// Items stored with a scaling expiration are rewritten so that the scale window starts
+// over from the new expiration time.
+func (c *Client) GetAndTouch(ctx context.Context, key string, exp uint32) (*Item, error) {
+	if !c.intercepted() {
+		return c.getAndTouch(ctx, key, exp)
+	}
+	res, err := c.intercept(ctx, &Operation{Name: "GetAndTouch", Opcode: protocol.GAT, Keys: []string{key}, Exp: exp}, func(ctx context.Context, op *Operation) (*Result, error) {
+		i, err := c.getAndTouch(ctx, op.Keys[0], op.Exp)
+		return &Result{Item: i}, err
+	})
+	return res.Item, err
+}
+
+func (c *Client) getAndTouch(ctx context.Context, key string, exp uint32) (*Item, error) {
+	extras := make([]byte, 4)
+	endian.PutUint32(extras, exp)
+	data, extra, cas, err := c.replicate(ctx, c.replicas(0), protocol.GAT, key, nil, extras, 0)
+	if err != nil {
+		return nil, err
+	}
+	i, envelope, err := c.unwrap(ctx, key, data, extra, cas)
+	if err != nil {
+		if envelope != nil {
+			c.untouch(ctx, key, envelope)
+		}
+		return nil, err
+	}
+	if envelope != nil && envelope.Expiration != nil {
+		if i.cas, err = c.retouch(ctx, key, envelope, extra, cas, exp); err != nil {
+			return nil, err
+		}
+	}
+	return i, nil
+}
+
+// Touch sets the expiration time of the item to exp seconds. GAT is used instead of
+// the touch command: the flags it returns tell whether the value carries a scaling
+// expiration envelope, which is rewritten so that the scale window starts over from
+// the new expiration time. Options.PlainTouch opts out of it.
+func (c *Client) Touch(ctx context.Context, key string, exp uint32) error {
+	if !c.intercepted() {
+		return c.touch(ctx, key, exp)
+	}
+	_, err := c.intercept(ctx, &Operation{Name: "Touch", Opcode: protocol.Touch, Keys: []string{key}, Exp: exp}, func(ctx context.Context, op *Operation) (*Result, error) {
+		return nil, c.touch(ctx, op.Keys[0], op.Exp)
+	})
+	return err
+}
+
+func (c *Client) touch(ctx context.Context, key string, exp uint32) error {
+	extras := make([]byte, 4)
+	endian.PutUint32(extras, exp)
+	if c.opts.PlainTouch {
+		_, _, _, err := c.replicate(ctx, c.replicas(0), protocol.Touch, key, nil, extras, 0)
+		return err
+	}
+	data, extra, cas, err := c.replicate(ctx, c.replicas(0), protocol.GAT, key, nil, extras, 0)
+	if err != nil {
+		return err
+	}
+	if len(extra) < 4 || extra[0] != MagicValue {
+		return nil
+	}
+	var envelope cache.Item
+	if err := proto.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	if envelope.Expiration != nil {
+		if envelope.Expiration.Until < clock.Unix() {
+			c.untouch(ctx, key, &envelope)
+			return ErrCacheMiss
+		}
+		if _, err := c.retouch(ctx, key, &envelope, extra, cas, exp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// untouch gives an expired scaling expiration envelope, whose server expiration GAT
+// has just extended, back the expiration it was stored with: the end of its scale
+// window.
+func (c *Client) untouch(ctx context.Context, key string, envelope *cache.Item) {
+	left := envelope.Expiration.Until + int64(envelope.Expiration.Scale) - clock.Unix()
+	if left <= 0 {
+		c.delete(ctx, key)
+		return
+	}
+	extras := make([]byte, 4)
+	endian.PutUint32(extras, uint32(left))
+	c.replicate(ctx, c.replicas(0), protocol.Touch, key, nil, extras, 0)
+}
+
+// unwrap decodes the value of key. An expired scaling expiration envelope the caller
+// has to repopulate comes back along with ErrCacheMiss.
+func (c *Client) unwrap(ctx context.Context, key string, data, extra []byte, cas uint64) (*Item, *cache.Item, error) {
 	var (
-		flags uint16
-		value = data
+		flags    uint16
+		value    = data
+		envelope *cache.Item
 	)
 
 	if len(extra) >= 4 {
@@ -59,34 +262,38 @@ func (c *Client) Get(ctx context.Context, key string) (*Item, error) {
 
 			var item cache.Item
 			if err := proto.Unmarshal(data, &item); err != nil {
-				return nil, err
+				return nil, nil, err
+			}
+			if span := spanFromContext(ctx); span != nil {
+				span.SetAttribute(AttrEnvelope, true)
+				if item.Namespace != nil {
+					span.SetAttribute(AttrNamespace, item.Namespace.Key)
+				}
 			}
 
 			if item.Expiration != nil && item.Expiration.Until < clock.Unix() {
-				err := c.Add(ctx, &Item{
-					Key: key + ":es",
-				}, WithExpiration(item.Expiration.Scale, 0))
+				err := c.scalingExpiration(ctx, key, item.Expiration.Scale)
 				switch err {
 				case nil:
-					return nil, ErrCacheMiss
+					return nil, &item, ErrCacheMiss
 				case ErrAlreadyExists:
 				default:
-					return nil, err
+					return nil, nil, err
 				}
 			}
 
 			if item.Namespace != nil {
 				v, err := c.nsVersion(ctx, item.Namespace.Key, 0)
 				if err != nil {
-					return nil, err
+					return nil, nil, err
 				}
 				if v != item.Namespace.Ver {
-					c.Delete(ctx, key)
-					return nil, ErrCacheMiss
+					c.delete(ctx, key)
+					return nil, nil, ErrCacheMiss
 				}
 			}
 
-			value = item.Data
+			value, envelope = item.Data, &item
 		}
 	}
 
@@ -95,17 +302,65 @@ func (c *Client) Get(ctx context.Context, key string) (*Item, error) {
 		Value: value,
 		Flags: flags,
 		cas:   cas,
-	}, nil
+	}, envelope, nil
 }
 
 func (c *Client) Set(ctx context.Context, i *Item, o ...Option) error {
-	return c.populateOne(ctx, protocol.Set, i, 0, o...)
+	return c.populate(ctx, "Set", protocol.Set, i, o)
 }
 func (c *Client) Add(ctx context.Context, i *Item, o ...Option) error {
-	return c.populateOne(ctx, protocol.Add, i, 0, o...)
+	return c.populate(ctx, "Add", protocol.Add, i, o)
+}
+
+// Replace stores the item only if the key already exists, otherwise ErrCacheMiss is returned.
+func (c *Client) Replace(ctx context.Context, i *Item, o ...Option) error {
+	return c.populate(ctx, "Replace", protocol.Replace, i, o)
+}
+
+// populate is populateOne through the interceptors.
+func (c *Client) populate(ctx context.Context, name string, opcode protocol.Opcode, i *Item, o []Option) error {
+	if !c.intercepted() {
+		return c.populateOne(ctx, opcode, i, 0, o...)
+	}
+	_, err := c.intercept(ctx, &Operation{Name: name, Opcode: opcode, Items: []*Item{i}, Options: o}, func(ctx context.Context, op *Operation) (*Result, error) {
+		return nil, c.populateOne(ctx, op.Opcode, op.Items[0], 0, op.Options...)
+	})
+	return err
+}
+
+// Append adds i.Value to the end of the existing item. It takes an extra Get
+// round-trip, see appendPrepend.
+func (c *Client) Append(ctx context.Context, i *Item) error {
+	return c.appendPrependOp(ctx, "Append", protocol.Append, i)
+}
+
+// Prepend adds i.Value to the beginning of the existing item. It takes an extra Get
+// round-trip, see appendPrepend.
+func (c *Client) Prepend(ctx context.Context, i *Item) error {
+	return c.appendPrependOp(ctx, "Prepend", protocol.Prepend, i)
+}
+
+func (c *Client) appendPrependOp(ctx context.Context, name string, opcode protocol.Opcode, i *Item) error {
+	if !c.intercepted() {
+		return c.appendPrepend(ctx, opcode, i)
+	}
+	_, err := c.intercept(ctx, &Operation{Name: name, Opcode: opcode, Items: []*Item{i}}, func(ctx context.Context, op *Operation) (*Result, error) {
+		return nil, c.appendPrepend(ctx, op.Opcode, op.Items[0])
+	})
+	return err
 }
 
 func (c *Client) CompareAndSwap(ctx context.Context, i *Item, o ...Option) error {
+	if !c.intercepted() {
+		return c.compareAndSwap(ctx, i, o...)
+	}
+	_, err := c.intercept(ctx, &Operation{Name: "CompareAndSwap", Opcode: protocol.Set, Items: []*Item{i}, Options: o}, func(ctx context.Context, op *Operation) (*Result, error) {
+		return nil, c.compareAndSwap(ctx, op.Items[0], op.Options...)
+	})
+	return err
+}
+
+func (c *Client) compareAndSwap(ctx context.Context, i *Item, o ...Option) error {
 	if err := c.populateOne(ctx, protocol.Set, i, i.cas, o...); err != nil {
 		if errors.Is(err, ErrAlreadyExists) {
 			return ErrCASConflict
@@ -116,19 +371,47 @@ func (c *Client) CompareAndSwap(ctx context.Context, i *Item, o ...Option) error
 }
 
 func (c *Client) Inc(ctx context.Context, key string, delta uint64, o ...Option) (uint64, error) {
+	if !c.intercepted() {
+		return c.inc(ctx, key, delta, o...)
+	}
+	res, err := c.intercept(ctx, &Operation{Name: "Inc", Opcode: protocol.Increment, Keys: []string{key}, Delta: delta, Options: o}, func(ctx context.Context, op *Operation) (*Result, error) {
+		v, err := c.inc(ctx, op.Keys[0], op.Delta, op.Options...)
+		return &Result{Value: v}, err
+	})
+	return res.Value, err
+}
+
+func (c *Client) inc(ctx context.Context, key string, delta uint64, o ...Option) (uint64, error) {
 	var opt opts
 	for _, fn := range o {
 		fn(&opt)
 	}
-	return c.incrDecr(ctx, protocol.Increment, key, delta, opt.initial, opt.expiration)
+	return c.incrDecr(ctx, protocol.Increment, key, delta, opt.initial, opt.expiration, c.replicas(opt.replicas))
 }
 
 func (c *Client) Dec(ctx context.Context, key string, delta uint64) (uint64, error) {
-	return c.incrDecr(ctx, protocol.Decrement, key, delta, 0, 0)
+	if !c.intercepted() {
+		return c.incrDecr(ctx, protocol.Decrement, key, delta, 0, 0, c.opts.ReplicationFactor)
+	}
+	res, err := c.intercept(ctx, &Operation{Name: "Dec", Opcode: protocol.Decrement, Keys: []string{key}, Delta: delta}, func(ctx context.Context, op *Operation) (*Result, error) {
+		v, err := c.incrDecr(ctx, protocol.Decrement, op.Keys[0], op.Delta, 0, 0, c.opts.ReplicationFactor)
+		return &Result{Value: v}, err
+	})
+	return res.Value, err
 }
 
 func (c *Client) Delete(ctx context.Context, key string) error {
-	if _, _, _, err := c.request(ctx, protocol.Delete, key, nil, nil, 0); err != nil {
+	if !c.intercepted() {
+		return c.delete(ctx, key)
+	}
+	_, err := c.intercept(ctx, &Operation{Name: "Delete", Opcode: protocol.Delete, Keys: []string{key}}, func(ctx context.Context, op *Operation) (*Result, error) {
+		return nil, c.delete(ctx, op.Keys[0])
+	})
+	return err
+}
+
+func (c *Client) delete(ctx context.Context, key string) error {
+	if _, _, _, err := c.replicate(ctx, c.opts.ReplicationFactor, protocol.Delete, key, nil, nil, 0); err != nil {
 		return err
 	}
 	return nil
@@ -136,6 +419,16 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 
 // https://github.com/memcached/memcached/wiki/ProgrammingTricks#namespacing
 func (c *Client) PurgeNamespace(ctx context.Context, ns string) error {
+	if !c.intercepted() {
+		return c.purgeNamespace(ctx, ns)
+	}
+	_, err := c.intercept(ctx, &Operation{Name: "PurgeNamespace", Opcode: protocol.Increment, Keys: []string{ns}}, func(ctx context.Context, op *Operation) (*Result, error) {
+		return nil, c.purgeNamespace(ctx, op.Keys[0])
+	})
+	return err
+}
+
+func (c *Client) purgeNamespace(ctx context.Context, ns string) error {
 	ns = fmt.Sprintf("%x", XXKeyHashFunc(ns))
 	if _, err := c.nsVersion(ctx, ns, 1); err != nil {
 		return err
@@ -143,7 +436,7 @@ func (c *Client) PurgeNamespace(ctx context.Context, ns string) error {
 	return nil
 }
 
-func (c *Client) incrDecr(ctx context.Context, opcode protocol.Opcode, key string, delta, initial uint64, expiration uint32) (uint64, error) {
+func (c *Client) incrDecr(ctx context.Context, opcode protocol.Opcode, key string, delta, initial uint64, expiration uint32, replicas int) (uint64, error) {
 	extras := make([]byte, 20)
 	switch {
 	case initial > 0:
@@ -159,7 +452,7 @@ func (c *Client) incrDecr(ctx context.Context, opcode protocol.Opcode, key strin
 	}
 	endian.PutUint64(extras, delta)
 
-	data, _, _, err := c.request(ctx, opcode, key, nil, extras, 0)
+	data, _, _, err := c.replicate(ctx, replicas, opcode, key, nil, extras, 0)
 	if err != nil {
 		return 0, err
 	}
@@ -171,6 +464,32 @@ func (c *Client) populateOne(ctx context.Context, opcode protocol.Opcode, i *Ite
 	for _, fn := range o {
 		fn(&opt)
 	}
+	ctx, span := c.startSpan(ctx, "", opcode)
+	if span != nil {
+		defer func() {
+			span.End(err)
+		}()
+	}
+	value, extras, skip, err := c.prepare(ctx, i, &opt)
+	if err != nil || skip {
+		return err
+	}
+	if span != nil {
+		span.SetAttribute(AttrValueSize, len(value))
+		span.SetAttribute(AttrEnvelope, extras[0] == MagicValue)
+		if opt.ns != nil {
+			span.SetAttribute(AttrNamespace, opt.ns.Key)
+		}
+	}
+	if _, _, i.cas, err = c.replicate(ctx, c.replicas(opt.replicas), opcode, i.Key, value, extras, cas); err != nil {
+		return err
+	}
+	return nil
+}
+
+// prepare returns the value and extras to store the item with. The item is skipped
+// if it hasn't been set WithMinUses times yet.
+func (c *Client) prepare(ctx context.Context, i *Item, opt *opts) (value, extras []byte, skip bool, err error) {
 	if opt.minUses > 0 {
 		var (
 			key        = i.Key + ":muc"
@@ -179,20 +498,20 @@ func (c *Client) populateOne(ctx context.Context, opcode protocol.Opcode, i *Ite
 		if expiration == 0 || expiration > 1_800 {
 			expiration = 1_800
 		}
-		switch uses, err := c.incrDecr(ctx, protocol.Increment, key, 1, 1, expiration); {
+		switch uses, err := c.minUses(ctx, key, expiration); {
 		case err != nil:
-			return err
+			return nil, nil, false, err
 		case uses < uint64(opt.minUses):
-			return nil
+			return nil, nil, true, nil
 		}
 	}
 	scaled := opt.expiration + opt.scalingExpiration
-	extras := make([]byte, 8)
+	extras = make([]byte, 8)
 	endian.PutUint16(extras[2:4], i.Flags) //uint16 flags
 	if opt.expiration != 0 {
 		endian.PutUint32(extras[4:8], uint32(scaled))
 	}
-	value := i.Value
+	value = i.Value
 
 	if (opt.expiration != 0 && opt.scalingExpiration != 0) || len(opt.namespace) != 0 {
 		extras[0] = MagicValue
@@ -206,27 +525,118 @@ func (c *Client) populateOne(ctx context.Context, opcode protocol.Opcode, i *Ite
 			}
 		}
 		if len(opt.namespace) != 0 {
-			ns := fmt.Sprintf("%x", XXKeyHashFunc(opt.namespace))
-			ver, err := c.nsVersion(ctx, ns, 0)
-			if err != nil {
-				return err
-			}
-			item.Namespace = &cache.Namespace{
-				Key: ns,
-				Ver: ver,
+			// resolved once per call, so all items of a batch share the version.
+			if opt.ns == nil {
+				ns := fmt.Sprintf("%x", XXKeyHashFunc(opt.namespace))
+				ver, err := c.nsVersion(ctx, ns, 0)
+				if err != nil {
+					return nil, nil, false, err
+				}
+				opt.ns = &cache.Namespace{
+					Key: ns,
+					Ver: ver,
+				}
 			}
+			item.Namespace = opt.ns
 		}
 		if value, err = proto.Marshal(item); err != nil {
-			return err
+			return nil, nil, false, err
 		}
 	}
+	return value, extras, false, nil
+}
 
-	if _, _, i.cas, err = c.request(ctx, opcode, i.Key, value, extras, cas); err != nil {
+// appendPrepend works on raw bytes only: values stored with a namespace or scaling
+// expiration are wrapped into the cache.Item envelope and would be corrupted, so the
+// current flags are read with a Get first and the update is guarded by its CAS.
+// An item with a CAS from a previous read is updated only if it's still the one
+// read. ErrNotStored is returned if the key doesn't exist, ErrEnvelopedValue if the
+// value is wrapped and ErrCASConflict if the item was changed since the read or in
+// between the two round-trips.
+func (c *Client) appendPrepend(ctx context.Context, opcode protocol.Opcode, i *Item) (err error) {
+	_, extra, cas, err := c.request(ctx, protocol.Get, i.Key, nil, nil, 0, nil)
+	switch {
+	case errors.Is(err, ErrCacheMiss):
+		return ErrNotStored
+	case err != nil:
+		return err
+	case len(extra) >= 4 && extra[0] == MagicValue:
+		return ErrEnvelopedValue
+	case i.cas != 0 && i.cas != cas:
+		return ErrCASConflict
+	}
+	if _, _, i.cas, err = c.replicate(ctx, c.replicas(0), opcode, i.Key, i.Value, nil, cas); err != nil {
+		switch {
+		case errors.Is(err, ErrAlreadyExists):
+			return ErrCASConflict
+		case errors.Is(err, ErrCacheMiss):
+			return ErrNotStored
+		}
 		return err
 	}
 	return nil
 }
 
-func (c *Client) nsVersion(ctx context.Context, ns string, delta uint64) (uint64, error) {
-	return c.Inc(ctx, ns+":ns", delta, WithInitial(uint64(clock.Unix())))
+// retouch moves Expiration.Until of the envelope to the new expiration time, so that
+// scaling expiration in Get stays consistent with the server TTL. The item is
+// overwritten only if it hasn't changed since it was read.
+func (c *Client) retouch(ctx context.Context, key string, envelope *cache.Item, flags []byte, cas uint64, exp uint32) (uint64, error) {
+	extras := make([]byte, 8)
+	copy(extras[0:4], flags)
+	switch exp {
+	case 0:
+		envelope.Expiration = nil
+	default:
+		envelope.Expiration.Until = clock.Unix() + int64(exp)
+		endian.PutUint32(extras[4:8], exp+envelope.Expiration.Scale)
+	}
+	value, err := proto.Marshal(envelope)
+	if err != nil {
+		return 0, err
+	}
+	_, _, cas, err = c.replicate(ctx, c.replicas(0), protocol.Set, key, value, extras, cas)
+	switch {
+	case err == nil:
+		return cas, nil
+	case errors.Is(err, ErrAlreadyExists), errors.Is(err, ErrCacheMiss):
+		// changed concurrently, the newer value wins.
+		return 0, nil
+	}
+	return 0, err
+}
+
+func (c *Client) nsVersion(ctx context.Context, ns string, delta uint64) (_ uint64, err error) {
+	ctx, span := c.startSpan(ctx, "mc.ns_version", protocol.Increment)
+	if span != nil {
+		defer func() {
+			span.End(err)
+		}()
+		span.SetAttribute(AttrNamespace, ns)
+	}
+	return c.incrDecr(ctx, protocol.Increment, ns+":ns", delta, uint64(clock.Unix()), 0, 1)
+}
+
+// scalingExpiration adds the key+":es" lock, only the first caller after the envelope
+// expired gets nil and recomputes the value.
+func (c *Client) scalingExpiration(ctx context.Context, key string, scale uint32) (err error) {
+	ctx, span := c.startSpan(ctx, "mc.scaling_expiration", protocol.Add)
+	if span != nil {
+		defer func() {
+			span.End(err)
+		}()
+	}
+	return c.populateOne(ctx, protocol.Add, &Item{
+		Key: key + ":es",
+	}, 0, WithExpiration(scale, 0))
+}
+
+// minUses counts sets of the key in key+":muc".
+func (c *Client) minUses(ctx context.Context, key string, expiration uint32) (_ uint64, err error) {
+	ctx, span := c.startSpan(ctx, "mc.min_uses", protocol.Increment)
+	if span != nil {
+		defer func() {
+			span.End(err)
+		}()
+	}
+	return c.incrDecr(ctx, protocol.Increment, key, 1, 1, expiration, 1)
 }
@@ -3,6 +3,7 @@ package mc_test
 import (
 	"bytes"
 	"context"
+	"os"
 	"testing"
 
 	"github.com/bradfitz/gomemcache/memcache"
@@ -66,6 +67,9 @@ func BenchmarkGet(b *testing.B) {
 }
 
 func BenchmarkOriginalGet(b *testing.B) {
+	if os.Getenv("MEMCACHED_ADDRS") == "" {
+		b.Skip("gomemcache speaks the text protocol, set MEMCACHED_ADDRS to run against real memcached")
+	}
 	cache := memcache.New(testServerAddrs...)
 	b.ReportAllocs()
 	b.ResetTimer()
@@ -79,3 +83,60 @@ func BenchmarkOriginalGet(b *testing.B) {
 		}
 	}
 }
+
+// BenchmarkGetInto reuses the buffer, allocations include those of the in-process
+// server unless MEMCACHED_ADDRS is set.
+func BenchmarkGetInto(b *testing.B) {
+	for name, o := range map[string]mc.Options{
+		"Server":    {Addrs: testServerAddrs[:1]},
+		"Servers":   {Addrs: testServerAddrs},
+		"Multiplex": {Addrs: testServerAddrs, MultiplexConnsPerAddr: 1},
+	} {
+		b.Run(name, func(b *testing.B) {
+			cache, err := mc.New(&o)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer cache.Close()
+			ctx := context.Background()
+			cache.Set(ctx, &mc.Item{
+				Key:   "benchmark_get",
+				Value: []byte("benchmark"),
+			})
+			b.ReportAllocs()
+			b.ResetTimer()
+			var buf []byte
+			for range b.N {
+				if buf, err = cache.GetInto(ctx, "benchmark_get", buf[:0]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSetLarge writes the value along with the header in one writev, allocations
+// are of the in-process server storing it unless MEMCACHED_ADDRS is set.
+func BenchmarkSetLarge(b *testing.B) {
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs[:1],
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cache.Close()
+	var (
+		ctx  = context.Background()
+		item = &mc.Item{
+			Key:   "benchmark_set",
+			Value: bytes.Repeat([]byte("v"), 64<<10),
+		}
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if err := cache.Set(ctx, item); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package mc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	errBadClusterConfig   = errors.New("memcache: malformed cluster config")
+	errLargeClusterConfig = errors.New("memcache: cluster config too large")
+)
+
+// maxClusterConfig bounds the length announced by the endpoint, which is allocated
+// upfront: thousands of servers take well under it.
+const maxClusterConfig = 1 << 20
+
+// ClusterConfigDiscovery polls the configuration endpoint of a managed memcached
+// cluster (such as AWS ElastiCache) with "config get cluster". Configs older than
+// the last one seen are ignored, an endpoint lagging behind doesn't roll the servers
+// back.
+type ClusterConfigDiscovery struct {
+	// Addr of the configuration endpoint.
+	Addr string
+
+	mu      sync.Mutex
+	version int64
+	servers []Server
+}
+
+func (d *ClusterConfigDiscovery) Servers(ctx context.Context) ([]Server, error) {
+	var dialer net.Dialer
+	nc, err := dialer.DialContext(ctx, "tcp", d.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer nc.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+	if _, err := io.WriteString(nc, "config get cluster\r\n"); err != nil {
+		return nil, err
+	}
+	version, servers, err := parseClusterConfig(bufio.NewReader(nc))
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if version < d.version {
+		return slices.Clone(d.servers), nil
+	}
+	d.version, d.servers = version, servers
+	return slices.Clone(servers), nil
+}
+
+// parseClusterConfig parses the response:
+//
+//	CONFIG cluster 0 <length>\r\n
+//	<version>\n
+//	<hostname>|<ip>|<port> <hostname>|<ip>|<port>...\n
+//	\r\n
+//	END\r\n
+func parseClusterConfig(r *bufio.Reader) (version int64, servers []Server, err error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "CONFIG cluster ") {
+		if strings.HasSuffix(line, "ERROR") || strings.HasPrefix(line, "SERVER_ERROR") || strings.HasPrefix(line, "CLIENT_ERROR") {
+			return 0, nil, fmt.Errorf("memcache: config get cluster: %s", line)
+		}
+		return 0, nil, errBadClusterConfig
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 4 {
+		return 0, nil, errBadClusterConfig
+	}
+	length, err := strconv.Atoi(fields[3])
+	switch {
+	case err != nil || length < 0:
+		return 0, nil, errBadClusterConfig
+	case length > maxClusterConfig:
+		return 0, nil, errLargeClusterConfig
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	if line, err = r.ReadString('\n'); err != nil {
+		return 0, nil, err
+	}
+	if line != "\r\n" {
+		return 0, nil, errBadClusterConfig
+	}
+	if line, err = r.ReadString('\n'); err != nil {
+		return 0, nil, err
+	}
+	if line != "END\r\n" {
+		return 0, nil, errBadClusterConfig
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 2 {
+		return 0, nil, errBadClusterConfig
+	}
+	if version, err = strconv.ParseInt(strings.TrimSpace(lines[0]), 10, 64); err != nil {
+		return 0, nil, errBadClusterConfig
+	}
+	for _, node := range strings.Fields(lines[1]) {
+		parts := strings.Split(node, "|")
+		if len(parts) != 3 {
+			return 0, nil, errBadClusterConfig
+		}
+		host := parts[1]
+		if host == "" {
+			host = parts[0]
+		}
+		if _, err := strconv.ParseUint(parts[2], 10, 16); err != nil || host == "" {
+			return 0, nil, errBadClusterConfig
+		}
+		servers = append(servers, Server{
+			Addr:   net.JoinHostPort(host, parts[2]),
+			Weight: 1,
+		})
+	}
+	return version, servers, nil
+}
@@ -0,0 +1,146 @@
+package mc_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/stretchr/testify/assert"
+)
+
+// configEndpoint is a fake configuration endpoint answering "config get cluster".
+type configEndpoint struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	response string
+}
+
+func newConfigEndpoint(t *testing.T) *configEndpoint {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &configEndpoint{ln: ln}
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go e.serve(nc)
+		}
+	}()
+	return e
+}
+
+func (e *configEndpoint) serve(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		e.mu.Lock()
+		response := e.response
+		e.mu.Unlock()
+		if line != "config get cluster\r\n" {
+			response = "ERROR\r\n"
+		}
+		if _, err := nc.Write([]byte(response)); err != nil {
+			return
+		}
+	}
+}
+
+func (e *configEndpoint) set(version int, srvs ...*mctest.Server) {
+	var nodes []string
+	for _, srv := range srvs {
+		host, port, _ := net.SplitHostPort(srv.Addr())
+		nodes = append(nodes, fmt.Sprintf("node%s.cache.local|%s|%s", port, host, port))
+	}
+	body := fmt.Sprintf("%d\n%s\n", version, strings.Join(nodes, " "))
+	e.setResponse(fmt.Sprintf("CONFIG cluster 0 %d\r\n%s\r\nEND\r\n", len(body), body))
+}
+
+func (e *configEndpoint) setResponse(response string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.response = response
+}
+
+func (e *configEndpoint) Close() error {
+	return e.ln.Close()
+}
+
+func TestClusterConfigDiscovery(t *testing.T) {
+	cluster := mctest.NewCluster(3)
+	defer cluster.Close()
+
+	endpoint := newConfigEndpoint(t)
+	defer endpoint.Close()
+	endpoint.set(1, cluster[:2]...)
+
+	cache, err := mc.New(&mc.Options{
+		Discovery:         &mc.ClusterConfigDiscovery{Addr: endpoint.ln.Addr().String()},
+		DiscoveryInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	servers := func() []string {
+		var addrs []string
+		for addr := range cache.Stats() {
+			addrs = append(addrs, addr)
+		}
+		return addrs
+	}
+	assert.ElementsMatch(t, cluster.Addrs()[:2], servers())
+
+	endpoint.set(2, cluster...)
+	assert.Eventually(t, func() bool {
+		return len(servers()) == 3
+	}, time.Second, time.Millisecond)
+
+	// an older config and errors keep the servers.
+	endpoint.set(1, cluster[0])
+	time.Sleep(50 * time.Millisecond)
+	assert.ElementsMatch(t, cluster.Addrs(), servers())
+	endpoint.setResponse("SERVER_ERROR out of memory\r\n")
+	time.Sleep(50 * time.Millisecond)
+	assert.ElementsMatch(t, cluster.Addrs(), servers())
+
+	endpoint.set(3, cluster[2])
+	assert.Eventually(t, func() bool {
+		return len(servers()) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{cluster[2].Addr()}, servers())
+}
+
+func TestClusterConfigDiscoveryErrors(t *testing.T) {
+	endpoint := newConfigEndpoint(t)
+	defer endpoint.Close()
+
+	for response, expected := range map[string]string{
+		"ERROR\r\n":                            "memcache: config get cluster: ERROR",
+		"CONFIG cluster 0 2\r\n1\n\r\nEND\r\n": "memcache: malformed cluster config",
+		"CONFIG cluster 0 16\r\n1\nhost|ip|port\n\r\nEND\r\n":   "memcache: malformed cluster config",
+		"CONFIG cluster 0 19\r\nv\nhost|1.1.1.1|1\n\r\nEND\r\n": "memcache: malformed cluster config",
+		"VALUE AmazonElastiCache:cluster 0 2\r\n1\n\r\nEND\r\n": "memcache: malformed cluster config",
+		"CONFIG cluster 0 2147483647\r\n1\n\r\nEND\r\n":         "memcache: cluster config too large",
+	} {
+		endpoint.setResponse(response)
+		_, err := mc.New(&mc.Options{
+			Discovery: &mc.ClusterConfigDiscovery{Addr: endpoint.ln.Addr().String()},
+		})
+		assert.EqualError(t, err, expected, response)
+	}
+}
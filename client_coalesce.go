@@ -0,0 +1,200 @@
+package mc
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+// coalescer collects concurrent Gets per server into pending batches, which are
+// sent as a GetKQ pipeline once BatchWindow passes or BatchMaxKeys keys are
+// collected.
+type coalescer struct {
+	mu      sync.Mutex
+	pending map[string]*coalesced
+}
+
+type coalesced struct {
+	addr    string
+	keys    []string
+	waiters map[string][]chan response
+	// deadline of the batch is the latest one of its callers, zero if any of
+	// them has none.
+	deadline time.Time
+	timer    *time.Timer
+	sent     bool
+}
+
+// coalesce adds the key to the pending batch of its server and waits for the result,
+// the value is appended to dst unless it's nil. If the server can't be connected to,
+// the Get is sent on its own to the next servers of the key. Every caller gets an
+// Observer event of its own, bytes are accounted in the event of the batch.
+func (c *Client) coalesce(ctx context.Context, start time.Time, key string, dst []byte) ([]byte, []byte, uint64, error) {
+	addrs := c.pickAddrs(key)
+	if len(addrs) == 0 {
+		return nil, nil, 0, ErrNoServers
+	}
+	var (
+		addr = addrs[0]
+		ch   = make(chan response, 1)
+		co   = &c.coalescer
+	)
+	co.mu.Lock()
+	b := co.pending[addr]
+	if b == nil {
+		b = &coalesced{
+			addr:     addr,
+			waiters:  make(map[string][]chan response),
+			deadline: time.Now(),
+		}
+		if co.pending == nil {
+			co.pending = make(map[string]*coalesced)
+		}
+		co.pending[addr] = b
+		b.timer = time.AfterFunc(c.opts.BatchWindow, func() {
+			c.flush(b)
+		})
+	}
+	if _, ok := b.waiters[key]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.waiters[key] = append(b.waiters[key], ch)
+	if deadline, ok := ctx.Deadline(); !ok {
+		b.deadline = time.Time{}
+	} else if !b.deadline.IsZero() && deadline.After(b.deadline) {
+		b.deadline = deadline
+	}
+	full := len(b.keys) >= c.opts.BatchMaxKeys
+	co.mu.Unlock()
+	if full {
+		b.timer.Stop()
+		go c.flush(b)
+	}
+
+	if span := spanFromContext(ctx); span != nil {
+		span.SetAttribute(AttrServer, addr)
+	}
+	select {
+	case r := <-ch:
+		if r.unreachable && len(addrs) > 1 && ctx.Err() == nil {
+			return c.exchangeFirst(ctx, start, addrs[1:], protocol.Get, key, nil, nil, 0, dst)
+		}
+		if c.opts.Observer != nil {
+			c.observe(protocol.Get, addr, nil, 1, start, r.err)
+		}
+		if dst != nil && r.err == nil {
+			r.data = append(dst, r.data...)
+		}
+		return r.data, r.extras, r.cas, opError(protocol.Get, addr, key, r.err)
+	case <-ctx.Done():
+		if c.opts.Observer != nil {
+			c.observe(protocol.Get, addr, nil, 1, start, ctx.Err())
+		}
+		return nil, nil, 0, ctx.Err()
+	}
+}
+
+// flush sends the batch unless it's been sent already, and hands the results over to
+// the callers still waiting.
+func (c *Client) flush(b *coalesced) {
+	co := &c.coalescer
+	co.mu.Lock()
+	if b.sent {
+		co.mu.Unlock()
+		return
+	}
+	b.sent = true
+	if co.pending[b.addr] == b {
+		delete(co.pending, b.addr)
+	}
+	co.mu.Unlock()
+
+	ctx := context.Background()
+	if !b.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, b.deadline)
+		defer cancel()
+	}
+	results := c.sendCoalesced(ctx, b.addr, b.keys)
+	for i, key := range b.keys {
+		for n, ch := range b.waiters[key] {
+			r := results[i]
+			// callers of the same key don't share the value.
+			if n > 0 {
+				r.data, r.extras = slices.Clone(r.data), slices.Clone(r.extras)
+			}
+			ch <- r
+		}
+	}
+}
+
+// sendCoalesced pipelines GetKQ for the keys, tagged with their indexes as Opaque,
+// followed by Noop. Keys without a response are misses, unless the connection failed.
+func (c *Client) sendCoalesced(ctx context.Context, addr string, keys []string) (results []response) {
+	var (
+		err   error
+		conn  *conn
+		start time.Time
+	)
+	results = make([]response, len(keys))
+	if c.opts.Observer != nil {
+		start = time.Now()
+	}
+	defer func() {
+		if c.opts.Observer != nil {
+			c.observe(protocol.GetKQ, addr, conn, len(keys), start, err)
+		}
+		for i := range results {
+			switch r := &results[i]; {
+			case r.hit || r.err != nil:
+			case err != nil:
+				r.err, r.unreachable = err, conn == nil
+			default:
+				r.err = ErrCacheMiss
+			}
+			c.pool.record(addr, protocol.GetKQ, results[i].err)
+		}
+	}()
+
+	if conn, err = c.pool.getConn(ctx, addr); err != nil {
+		return results
+	}
+	defer func() {
+		c.pool.condRelease(ctx, conn, err)
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.nc.SetDeadline(deadline)
+		defer conn.nc.SetDeadline(time.Time{})
+	}
+	for i, key := range keys {
+		if err = conn.writePacket(protocol.GetKQ, uint32(i+1), c.opts.KeyHashFunc(key), nil, nil, 0); err != nil {
+			return results
+		}
+	}
+	if err = conn.sendPacketOpaque(protocol.Noop, 0, nil, nil, nil, 0); err != nil {
+		return results
+	}
+	for {
+		packet, e := conn.readPacket()
+		// errors of a key are tagged with its Opaque, others are of the connection.
+		if n := int(packet.Opaque); e != nil && n > 0 && n <= len(keys) {
+			results[n-1].err = e
+			continue
+		}
+		if err = e; err != nil || packet.Opcode == protocol.Noop {
+			return results
+		}
+		if n := int(packet.Opaque); n > 0 && n <= len(keys) {
+			results[n-1] = response{
+				addr:   addr,
+				data:   packet.Data,
+				extras: packet.Extras,
+				cas:    packet.CAS,
+				hit:    true,
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package mc_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/kinescope/mc/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchWindow(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	var rec recorder
+	cache, err := mc.New(&mc.Options{
+		Addrs:       []string{srv.Addr()},
+		Observer:    &rec,
+		BatchWindow: 5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	keys := make([]string, 0, 50)
+	for n := range 50 {
+		k := randSeq(16)
+		if n%2 == 0 {
+			if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k), Flags: 42}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		keys = append(keys, k)
+	}
+	rec.mu.Lock()
+	rec.events = nil
+	rec.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for n, k := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i, err := cache.Get(ctx, k)
+			if n%2 != 0 {
+				assert.Equal(t, mc.ErrCacheMiss, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, k, string(i.Value))
+				assert.Equal(t, uint16(42), i.Flags)
+			}
+		}()
+	}
+	wg.Wait()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var batched, batches, callers int
+	for _, e := range rec.events {
+		switch e.Opcode {
+		case protocol.GetKQ:
+			batched += e.Keys
+			batches++
+		case protocol.Get:
+			// every caller is observed, bytes are of the batch.
+			assert.Zero(t, e.Sent)
+			callers++
+		}
+	}
+	assert.Equal(t, 50, batched)
+	assert.Less(t, batches, 50)
+	assert.Equal(t, 50, callers)
+	if s := cache.Stats()[srv.Addr()]; assert.NotNil(t, s.Ops) {
+		assert.Equal(t, mc.OpStats{Hits: 25, Misses: 25}, s.Ops[protocol.GetKQ])
+	}
+}
+
+func TestBatchMaxKeys(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:        []string{srv.Addr()},
+		BatchWindow:  time.Hour,
+		BatchMaxKeys: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx = context.Background()
+		wg  sync.WaitGroup
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: "key", Value: []byte("value")}); err != nil {
+		t.Fatal(err)
+	}
+	srv.FailNext(protocol.StatusBusy, 1)
+	errs := make(chan error, 4)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.Get(ctx, k)
+			errs <- err
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the full batch isn't sent")
+	}
+	close(errs)
+	var busy, misses int
+	for err := range errs {
+		switch {
+		case errors.Is(err, mc.ErrBusy):
+			busy++
+		case err == mc.ErrCacheMiss:
+			misses++
+		}
+	}
+	assert.Equal(t, 1, busy, "the failure is of a single key")
+	assert.Equal(t, 3, misses)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = cache.Get(ctx, "key")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestBatchFailover(t *testing.T) {
+	var (
+		srv  = mctest.NewServer()
+		dead = mctest.NewServer()
+	)
+	defer srv.Close()
+	dead.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:       []string{dead.Addr(), srv.Addr()},
+		BatchWindow: time.Millisecond,
+		PickServer: func(key string) []string {
+			return []string{dead.Addr(), srv.Addr()}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx = context.Background()
+		k   = randSeq(16)
+	)
+	// Set moves on to the next server as well.
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+		assert.Equal(t, k, string(i.Value))
+	}
+}
+
+func TestBatchSameKey(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:       []string{srv.Addr()},
+		BatchWindow: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx   = context.Background()
+		k     = randSeq(16)
+		wg    sync.WaitGroup
+		items = make([]*mc.Item, 2)
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte("value")}); err != nil {
+		t.Fatal(err)
+	}
+	for n := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			items[n], _ = cache.Get(ctx, k)
+		}()
+	}
+	wg.Wait()
+	if assert.NotNil(t, items[0]) && assert.NotNil(t, items[1]) {
+		// callers of the same key get values of their own.
+		items[0].Value[0] = 'V'
+		assert.Equal(t, "value", string(items[1].Value))
+	}
+}
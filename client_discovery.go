@@ -0,0 +1,132 @@
+package mc
+
+import (
+	"cmp"
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDiscoveryInterval = 30 * time.Second
+	DefaultPort              = 11211
+)
+
+// ServerDiscovery looks up the servers, see Options.Discovery.
+type ServerDiscovery interface {
+	Servers(ctx context.Context) ([]Server, error)
+}
+
+// Resolver is the part of *net.Resolver DNSDiscovery uses.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// DNSDiscovery resolves a DNS name to the servers: every A/AAAA record is a server
+// on Port, or with SRV set every SRV record of the lowest priority is a server
+// weighted by the record's weight.
+type DNSDiscovery struct {
+	// Name is a host name, or the full SRV name such as "_memcache._tcp.example.com".
+	Name string
+	// Port of the A/AAAA servers, DefaultPort if 0.
+	Port int
+	SRV  bool
+	// Resolver is net.DefaultResolver if nil.
+	Resolver Resolver
+}
+
+func (d *DNSDiscovery) Servers(ctx context.Context) ([]Server, error) {
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if d.SRV {
+		return d.lookupSRV(ctx, resolver)
+	}
+	hosts, err := resolver.LookupHost(ctx, d.Name)
+	if err != nil {
+		return nil, err
+	}
+	port := d.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	servers := make([]Server, 0, len(hosts))
+	for _, host := range hosts {
+		servers = append(servers, Server{
+			Addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+			Weight: 1,
+		})
+	}
+	return servers, nil
+}
+
+func (d *DNSDiscovery) lookupSRV(ctx context.Context, resolver Resolver) ([]Server, error) {
+	_, records, err := resolver.LookupSRV(ctx, "", "", d.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	// records of higher priorities are backups. Records are sorted by priority only
+	// by net.Resolver, so the lowest one is looked for.
+	var (
+		servers  []Server
+		priority = slices.MinFunc(records, func(a, b *net.SRV) int {
+			return cmp.Compare(a.Priority, b.Priority)
+		}).Priority
+	)
+	for _, r := range records {
+		if r.Priority != priority {
+			continue
+		}
+		servers = append(servers, Server{
+			Addr:   net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))),
+			Weight: max(int(r.Weight), 1),
+		})
+	}
+	return servers, nil
+}
+
+// discover looks up the servers sorted by address, so that lookups can be compared.
+func discover(ctx context.Context, d ServerDiscovery) ([]Server, error) {
+	servers, err := d.Servers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, ErrNoServers
+	}
+	slices.SortFunc(servers, func(a, b Server) int {
+		return strings.Compare(a.Addr, b.Addr)
+	})
+	return servers, nil
+}
+
+// refreshServers polls Options.Discovery until the client is closed. Failed lookups
+// keep the current servers.
+func (c *Client) refreshServers(current []Server) {
+	ticker := time.NewTicker(c.opts.DiscoveryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.pool.done:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), c.opts.DiscoveryInterval)
+		servers, err := discover(ctx, c.opts.Discovery)
+		cancel()
+		if err != nil || slices.Equal(servers, current) {
+			continue
+		}
+		if err := c.setServers(servers); err == nil {
+			current = servers
+		}
+	}
+}
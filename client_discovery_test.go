@@ -0,0 +1,142 @@
+package mc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubResolver struct {
+	mu    sync.Mutex
+	hosts []string
+	srv   []*net.SRV
+	err   error
+}
+
+func (r *stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hosts, r.err
+}
+
+func (r *stubResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return name, r.srv, r.err
+}
+
+func (r *stubResolver) set(srv []*net.SRV, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.srv, r.err = srv, err
+}
+
+func srvRecords(t *testing.T, srvs ...*mctest.Server) []*net.SRV {
+	records := make([]*net.SRV, 0, len(srvs))
+	for i, srv := range srvs {
+		host, port, err := net.SplitHostPort(srv.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _ := strconv.Atoi(port)
+		records = append(records, &net.SRV{Target: host + ".", Port: uint16(p), Weight: uint16(i + 1)})
+	}
+	return records
+}
+
+func TestDNSDiscovery(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		resolver = &stubResolver{
+			hosts: []string{"10.0.0.1", "::1"},
+			srv: []*net.SRV{
+				{Target: "a.example.com.", Port: 11211, Priority: 1, Weight: 10},
+				{Target: "b.example.com.", Port: 11212, Priority: 1},
+				{Target: "backup.example.com.", Port: 11211, Priority: 2, Weight: 10},
+			},
+		}
+	)
+	servers, err := (&mc.DNSDiscovery{Name: "memcached", Resolver: resolver}).Servers(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []mc.Server{
+			{Addr: "10.0.0.1:11211", Weight: 1},
+			{Addr: "[::1]:11211", Weight: 1},
+		}, servers)
+	}
+	servers, err = (&mc.DNSDiscovery{Name: "memcached", Port: 11222, Resolver: resolver}).Servers(ctx)
+	if assert.NoError(t, err) && assert.Len(t, servers, 2) {
+		assert.Equal(t, "10.0.0.1:11222", servers[0].Addr)
+	}
+	servers, err = (&mc.DNSDiscovery{Name: "_memcache._tcp.example.com", SRV: true, Resolver: resolver}).Servers(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []mc.Server{
+			{Addr: "a.example.com:11211", Weight: 10},
+			{Addr: "b.example.com:11212", Weight: 1},
+		}, servers)
+	}
+	// custom resolvers may return the records unsorted.
+	resolver.set([]*net.SRV{
+		{Target: "backup.example.com.", Port: 11211, Priority: 2, Weight: 10},
+		{Target: "a.example.com.", Port: 11211, Priority: 1, Weight: 10},
+	}, nil)
+	servers, err = (&mc.DNSDiscovery{Name: "_memcache._tcp.example.com", SRV: true, Resolver: resolver}).Servers(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []mc.Server{{Addr: "a.example.com:11211", Weight: 10}}, servers)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	cluster := mctest.NewCluster(3)
+	defer cluster.Close()
+
+	resolver := &stubResolver{srv: srvRecords(t, cluster[:2]...)}
+	cache, err := mc.New(&mc.Options{
+		Discovery: &mc.DNSDiscovery{
+			Name:     "_memcache._tcp.example.com",
+			SRV:      true,
+			Resolver: resolver,
+		},
+		DiscoveryInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	servers := func() []string {
+		var addrs []string
+		for addr := range cache.Stats() {
+			addrs = append(addrs, addr)
+		}
+		return addrs
+	}
+	assert.ElementsMatch(t, cluster.Addrs()[:2], servers())
+
+	resolver.set(srvRecords(t, cluster[1:]...), nil)
+	assert.Eventually(t, func() bool {
+		_, ok := cache.Stats()[cluster[2].Addr()]
+		return ok
+	}, time.Second, time.Millisecond)
+	assert.ElementsMatch(t, cluster.Addrs()[1:], servers())
+
+	// failed and empty lookups keep the servers.
+	resolver.set(nil, errors.New("lookup failed"))
+	time.Sleep(50 * time.Millisecond)
+	assert.ElementsMatch(t, cluster.Addrs()[1:], servers())
+	resolver.set(nil, nil)
+	time.Sleep(50 * time.Millisecond)
+	assert.ElementsMatch(t, cluster.Addrs()[1:], servers())
+
+	_, err = mc.New(&mc.Options{
+		Discovery: &mc.DNSDiscovery{Name: "memcached", Resolver: &stubResolver{err: errors.New("lookup failed")}},
+	})
+	assert.EqualError(t, err, "lookup failed")
+}
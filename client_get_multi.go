@@ -2,6 +2,7 @@ package mc
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/kinescope/mc/internal/clock"
@@ -10,99 +11,370 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-func (c *Client) GetMulti(ctx context.Context, keys ...string) (_ map[string]*Item, retErr error) {
+// GetMulti gets the keys in one round-trip per server. Missing keys are absent from the
+// result. If some of the servers or keys failed, the partial result is returned along
+// with a *MultiError describing the failures.
+func (c *Client) GetMulti(ctx context.Context, keys ...string) (map[string]*Item, error) {
+	return c.getMultiOp(ctx, "GetMulti", protocol.GetKQ, 0, keys)
+}
+
+// GetAndTouchMulti is GetMulti that also sets expiration time of the found items
+// to exp seconds, see GetAndTouch.
+func (c *Client) GetAndTouchMulti(ctx context.Context, exp uint32, keys ...string) (map[string]*Item, error) {
+	return c.getMultiOp(ctx, "GetAndTouchMulti", protocol.GATKQ, exp, keys)
+}
+
+func (c *Client) getMultiOp(ctx context.Context, name string, opcode protocol.Opcode, exp uint32, keys []string) (map[string]*Item, error) {
+	if !c.intercepted() {
+		return c.getMulti(ctx, opcode, exp, keys)
+	}
+	res, err := c.intercept(ctx, &Operation{Name: name, Opcode: opcode, Keys: keys, Exp: exp}, func(ctx context.Context, op *Operation) (*Result, error) {
+		items, err := c.getMulti(ctx, op.Opcode, op.Exp, op.Keys)
+		return &Result{Items: items}, err
+	})
+	return res.Items, err
+}
+
+func (c *Client) getMulti(ctx context.Context, opcode protocol.Opcode, exp uint32, keys []string) (items map[string]*Item, err error) {
+	name := "mc.get_multi"
+	if opcode == protocol.GATKQ {
+		name = "mc.get_and_touch_multi"
+	}
+	ctx, span := c.startSpan(ctx, name, opcode)
+	if span != nil {
+		defer func() {
+			span.SetAttribute(AttrKeys, len(keys))
+			span.SetAttribute(AttrHit, len(items))
+			span.End(err)
+		}()
+	}
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
 	}
-	keyMap := make(map[string][]string)
+	if c.pool.closed.Load() {
+		return nil, ErrClientClosed
+	}
+	var (
+		keyMap = make(map[string][]string)
+		// replicas of the keys in PickServer order, the first one is tried first.
+		replicas = make(map[string][]string, len(keys))
+	)
 	for _, key := range keys {
 		if !checkKey(key) {
 			return nil, ErrMalformedKey
 		}
-		addrs := c.opts.PickServer(key)
+		addrs := c.pickAddrs(key)
 		if len(addrs) == 0 {
 			return nil, ErrNoServers
 		}
 		keyMap[addrs[0]] = append(keyMap[addrs[0]], key)
+		replicas[key] = addrs
 	}
 
-	var chs []chan *Item
-
-	for addr, keys := range keyMap {
-		ch := make(chan *Item)
-		chs = append(chs, ch)
-		go func(addr string, keys []string, ch chan *Item) {
-			defer close(ch)
-			conn, err := c.pool.getConn(addr)
-			if err != nil {
-				return
+	var (
+		report MultiError
+		// replicated keys missed by a replica are looked up on the next one.
+		fallThrough = make(map[string]int)
+		retouched   = make(map[string]bool)
+	)
+	if n := c.opts.ReplicationFactor; n > 1 {
+		for _, key := range keys {
+			fallThrough[key] = n - 1
+		}
+	}
+	items = make(map[string]*Item)
+	// keys of a failed server are regrouped onto their next replica that hasn't
+	// failed yet, the same way pickServer moves on to the next address.
+	for len(keyMap) != 0 {
+		ch := make(chan *batch, len(keyMap))
+		for addr, keys := range keyMap {
+			go func(addr string, keys []string) {
+				ch <- c.getBatch(ctx, opcode, exp, addr, keys)
+			}(addr, keys)
+		}
+		var (
+			// keys to fail over with the error of their last server.
+			next   = make(map[string]error)
+			missed []string
+		)
+		for range keyMap {
+			b := <-ch
+			for _, item := range b.items {
+				items[item.Key] = item
 			}
-			defer c.pool.condRelease(conn, err)
-			if deadline, ok := ctx.Deadline(); ok {
-				conn.nc.SetDeadline(deadline)
-				defer conn.nc.SetDeadline(time.Time{})
+			for key, err := range b.errs {
+				report.addKey(key, err)
 			}
-
-			names := make(map[string]string, len(keys))
-			for _, k := range keys {
-				h := c.opts.KeyHashFunc(k)
-				names[string(h)] = k
-				err = conn.sendPacket(protocol.GetKQ, h, nil, nil, 0)
-				if err != nil {
-					return
-				}
+			for _, key := range b.retouched {
+				retouched[key] = true
 			}
-			if err = conn.sendPacket(protocol.Noop, nil, nil, nil, 0); err != nil {
-				return
+			if b.err != nil {
+				report.addServer(b.addr, b.err)
+				for _, key := range b.failed {
+					replicas[key] = replicas[key][1:]
+					next[key] = b.err
+				}
 			}
-			var packet *protocol.Packet
-			for {
-				packet, err = conn.readPacket()
-				if err != nil || len(packet.Key) == 0 {
-					break
+			for _, key := range keyMap[b.addr] {
+				if _, failed := next[key]; items[key] == nil && b.errs[key] == nil && !failed && fallThrough[key] > 0 {
+					fallThrough[key]--
+					replicas[key] = replicas[key][1:]
+					missed = append(missed, key)
 				}
-				var (
-					flags uint16
-					value = packet.Data
-				)
+			}
+		}
+		keyMap = make(map[string][]string)
+		for _, key := range missed {
+			if len(replicas[key]) != 0 && report.Servers[replicas[key][0]] == nil && ctx.Err() == nil {
+				keyMap[replicas[key][0]] = append(keyMap[replicas[key][0]], key)
+			}
+		}
+		for key := range next {
+			for len(replicas[key]) != 0 && report.Servers[replicas[key][0]] != nil {
+				replicas[key] = replicas[key][1:]
+			}
+			if len(replicas[key]) == 0 || ctx.Err() != nil {
+				continue
+			}
+			keyMap[replicas[key][0]] = append(keyMap[replicas[key][0]], key)
+			delete(next, key)
+		}
+		for key, err := range next {
+			report.addKey(key, err)
+		}
+	}
+	if n := c.opts.ReplicationFactor; opcode == protocol.GATKQ && n > 1 {
+		c.touchReplicas(ctx, n, exp, items, retouched, &report)
+	}
+	if len(report.Keys) != 0 {
+		return items, &report
+	}
+	return items, nil
+}
+
+// touchReplicas sets the expiration time of the items on all of their n replicas,
+// GATKQ has touched only the one each item was read from.
+func (c *Client) touchReplicas(ctx context.Context, n int, exp uint32, items map[string]*Item, retouched map[string]bool, report *MultiError) {
+	extras := make([]byte, 4)
+	endian.PutUint32(extras, exp)
+	reqs := make([]multiRequest, 0, len(items))
+	for key := range items {
+		if !retouched[key] {
+			reqs = append(reqs, multiRequest{key: key, extras: extras})
+		}
+	}
+	if len(reqs) == 0 {
+		return
+	}
+	errs := make(map[string]error)
+	if err := c.sendMulti(ctx, protocol.Touch, n, reqs, errs); err != nil {
+		for _, r := range reqs {
+			report.addKey(r.key, err)
+		}
+		return
+	}
+	for key, err := range errs {
+		// evicted since it was read.
+		if !errors.Is(err, ErrCacheMiss) {
+			report.addKey(key, err)
+		}
+	}
+}
 
-				if len(packet.Extras) >= 4 {
-					flags = endian.Uint16(packet.Extras[2:])
-					if packet.Extras[0] == MagicValue {
-						var item cache.Item
-						if err := proto.Unmarshal(packet.Data, &item); err != nil {
-							continue
-						}
-						if item.Expiration != nil && item.Expiration.Until < clock.Unix() {
-							continue
-						}
-						if item.Namespace != nil {
-							if v, err := c.nsVersion(ctx, item.Namespace.Key, 0); err == nil {
-								if v != item.Namespace.Ver {
-									c.Delete(ctx, names[string(packet.Key)])
-									continue
-								}
-							}
-						}
-						value = item.Data
+type batch struct {
+	addr  string
+	items []*Item
+	// errs of individual keys, the server is fine.
+	errs map[string]error
+	// err of the server, the outcome of failed keys is unknown.
+	err    error
+	failed []string
+	// retouched keys have been rewritten on every replica.
+	retouched []string
+}
+
+// getBatch pipelines GetKQ (or GATKQ) for the keys followed by Noop.
+func (c *Client) getBatch(ctx context.Context, opcode protocol.Opcode, exp uint32, addr string, keys []string) (b *batch) {
+	b = &batch{
+		addr: addr,
+		errs: make(map[string]error),
+	}
+	var (
+		envelopes []envelope
+		answered  map[string]bool
+		err       error
+	)
+	ctx, span := c.startSpan(ctx, "mc.batch", opcode)
+	if span != nil {
+		span.SetAttribute(AttrServer, addr)
+		span.SetAttribute(AttrKeys, len(keys))
+		defer func() {
+			span.SetAttribute(AttrHit, len(b.items))
+			span.End(err)
+		}()
+	}
+	envelopes, answered, err = c.readBatch(ctx, b, opcode, exp, addr, keys)
+	if err != nil {
+		b.err = opError(opcode, addr, "", err)
+		for _, k := range keys {
+			if !answered[k] {
+				b.failed = append(b.failed, k)
+			}
+		}
+	}
+	// the connection is released by now, envelopes may need requests of their own.
+	for _, e := range envelopes {
+		if e.expired {
+			c.untouch(ctx, e.item.Key, e.envelope)
+			continue
+		}
+		if e.envelope.Namespace != nil {
+			v, err := c.nsVersion(ctx, e.envelope.Namespace.Key, 0)
+			if err != nil {
+				b.errs[e.item.Key] = err
+				continue
+			}
+			if v != e.envelope.Namespace.Ver {
+				c.delete(ctx, e.item.Key)
+				continue
+			}
+		}
+		if opcode == protocol.GATKQ && e.envelope.Expiration != nil {
+			cas, err := c.retouch(ctx, e.item.Key, e.envelope, e.flags, e.item.cas, exp)
+			if err != nil {
+				b.errs[e.item.Key] = err
+				continue
+			}
+			e.item.cas, b.retouched = cas, append(b.retouched, e.item.Key)
+		}
+		b.items = append(b.items, e.item)
+	}
+
+	hits := make(map[string]bool, len(b.items))
+	for _, i := range b.items {
+		hits[i.Key] = true
+	}
+	for _, k := range keys {
+		switch {
+		case hits[k]:
+			c.pool.record(addr, opcode, nil)
+		case b.errs[k] != nil:
+			c.pool.record(addr, opcode, b.errs[k])
+		case err != nil && !answered[k]:
+			c.pool.record(addr, opcode, err)
+		default:
+			c.pool.record(addr, opcode, ErrCacheMiss)
+		}
+	}
+	return b
+}
+
+// readBatch sends the pipeline and reads the responses into b, values in envelopes
+// that need further requests are returned to be resolved once the connection is
+// released.
+func (c *Client) readBatch(ctx context.Context, b *batch, opcode protocol.Opcode, exp uint32, addr string, keys []string) (envelopes []envelope, answered map[string]bool, err error) {
+	var (
+		conn  *conn
+		start time.Time
+	)
+	answered = make(map[string]bool, len(keys))
+	if c.opts.Observer != nil {
+		start = time.Now()
+	}
+	if conn, err = c.pool.getConn(ctx, addr); err != nil {
+		if c.opts.Observer != nil {
+			c.observe(opcode, addr, nil, len(keys), start, err)
+		}
+		return nil, answered, err
+	}
+	defer func() {
+		if c.opts.Observer != nil {
+			c.observe(opcode, addr, conn, len(keys), start, err)
+		}
+		c.pool.condRelease(ctx, conn, err)
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.nc.SetDeadline(deadline)
+		defer conn.nc.SetDeadline(time.Time{})
+	}
+
+	var extras []byte
+	if opcode == protocol.GATKQ {
+		extras = make([]byte, 4)
+		endian.PutUint32(extras, exp)
+	}
+	names := make(map[string]string, len(keys))
+	for _, k := range keys {
+		h := c.opts.KeyHashFunc(k)
+		names[string(h)] = k
+		if err = conn.writePacket(opcode, 0, h, nil, extras, 0); err != nil {
+			return envelopes, answered, err
+		}
+	}
+	if err = conn.sendPacket(protocol.Noop, nil, nil, nil, 0); err != nil {
+		return envelopes, answered, err
+	}
+	var packet *protocol.Packet
+	for {
+		if packet, err = conn.readPacket(); err != nil {
+			return envelopes, answered, err
+		}
+		if len(packet.Key) == 0 {
+			return envelopes, answered, nil
+		}
+		key := names[string(packet.Key)]
+		answered[key] = true
+		var (
+			flags uint16
+			value = packet.Data
+		)
+
+		if len(packet.Extras) >= 4 {
+			flags = endian.Uint16(packet.Extras[2:])
+			if packet.Extras[0] == MagicValue {
+				var item cache.Item
+				if err := proto.Unmarshal(packet.Data, &item); err != nil {
+					b.errs[key] = err
+					continue
+				}
+				if item.Expiration != nil && item.Expiration.Until < clock.Unix() {
+					if opcode == protocol.GATKQ {
+						envelopes = append(envelopes, envelope{item: &Item{Key: key}, envelope: &item, expired: true})
 					}
+					continue
 				}
-				ch <- &Item{
-					Key:   names[string(packet.Key)],
-					Value: value,
-					Flags: flags,
-					cas:   packet.CAS,
+				if item.Namespace != nil || opcode == protocol.GATKQ && item.Expiration != nil {
+					envelopes = append(envelopes, envelope{
+						item: &Item{
+							Key:   key,
+							Value: item.Data,
+							Flags: flags,
+							cas:   packet.CAS,
+						},
+						envelope: &item,
+						flags:    packet.Extras[:4],
+					})
+					continue
 				}
+				value = item.Data
 			}
-		}(addr, keys, ch)
-	}
-	items := make(map[string]*Item)
-	for _, ch := range chs {
-		for item := range ch {
-			items[item.Key] = item
 		}
+		b.items = append(b.items, &Item{
+			Key:   key,
+			Value: value,
+			Flags: flags,
+			cas:   packet.CAS,
+		})
 	}
-	return items, nil
+}
+
+type envelope struct {
+	item     *Item
+	envelope *cache.Item
+	// flags are the extras the value was stored with.
+	flags []byte
+	// expired envelopes are misses whose server expiration GATKQ extended.
+	expired bool
 }
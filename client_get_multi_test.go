@@ -3,10 +3,13 @@ package mc_test
 import (
 	"context"
 	"fmt"
+	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -173,3 +176,138 @@ func TestGetMultiNamespace(t *testing.T) {
 		assert.Len(t, list, 0)
 	}
 }
+
+func TestGetAndTouchMulti(t *testing.T) {
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx    = context.Background()
+		keyVal = make(map[string]string)
+		keys   []string
+	)
+	for n := range 20 {
+		var (
+			k = fmt.Sprintf("%s_%d", randSeq(16), n)
+			v = fmt.Sprintf("%s_%d", randSeq(16), n)
+		)
+		scale := uint32(0)
+		if n%2 == 0 {
+			scale = 1
+		}
+		err := cache.Set(ctx, &mc.Item{
+			Key:   k,
+			Value: []byte(v),
+		}, mc.WithExpiration(1, scale))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyVal[k] = v
+		keys = append(keys, k)
+	}
+	if list, err := cache.GetAndTouchMulti(ctx, 10, keys...); assert.NoError(t, err) {
+		assert.Len(t, list, len(keys))
+	}
+	time.Sleep(3 * time.Second)
+	if list, err := cache.GetMulti(ctx, keys...); assert.NoError(t, err) {
+		if assert.Len(t, list, len(keys)) {
+			for k, v := range keyVal {
+				assert.Equal(t, v, string(list[k].Value))
+			}
+		}
+	}
+}
+
+func TestGetMultiPartialFailure(t *testing.T) {
+	var (
+		alive = mctest.NewServer()
+		dead  = mctest.NewServer()
+	)
+	defer alive.Close()
+	dead.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs: []string{alive.Addr(), dead.Addr()},
+		PickServer: func(key string) []string {
+			if strings.HasPrefix(key, "dead") {
+				return []string{dead.Addr()}
+			}
+			return []string{alive.Addr()}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx      = context.Background()
+		keys     []string
+		deadKeys = make(map[string]bool)
+	)
+	for n := range 10 {
+		k := fmt.Sprintf("alive_%s_%d", randSeq(8), n)
+		if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+	for n := range 5 {
+		k := fmt.Sprintf("dead_%s_%d", randSeq(8), n)
+		deadKeys[k] = true
+		keys = append(keys, k)
+	}
+	list, err := cache.GetMulti(ctx, keys...)
+	if assert.Error(t, err) {
+		var e *mc.MultiError
+		if assert.ErrorAs(t, err, &e) {
+			assert.Len(t, e.Servers, 1)
+			assert.Contains(t, e.Servers, dead.Addr())
+			assert.Len(t, e.Keys, len(deadKeys))
+			for k := range deadKeys {
+				assert.Contains(t, e.Keys, k)
+			}
+			var netErr net.Error
+			assert.ErrorAs(t, err, &netErr)
+		}
+	}
+	if assert.Len(t, list, len(keys)-len(deadKeys)) {
+		for k, i := range list {
+			assert.Equal(t, k, string(i.Value))
+		}
+	}
+}
+
+func TestGetMultiFailover(t *testing.T) {
+	cluster := mctest.NewCluster(3)
+	defer cluster.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs: cluster.Addrs(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster[0].Close()
+
+	var (
+		ctx  = context.Background()
+		keys []string
+	)
+	// Set fails over to the next server as well, so every key is readable.
+	for n := range 30 {
+		k := fmt.Sprintf("%s_%d", randSeq(16), n)
+		if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+	if list, err := cache.GetMulti(ctx, keys...); assert.NoError(t, err) {
+		if assert.Len(t, list, len(keys)) {
+			for k, i := range list {
+				assert.Equal(t, k, string(i.Value))
+			}
+		}
+	}
+}
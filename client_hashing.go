@@ -0,0 +1,163 @@
+package mc
+
+import (
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dgryski/go-ketama"
+)
+
+// Server is a memcached server with a weight, servers get keys in proportion to
+// their weights. Weight 0 means 1.
+type Server struct {
+	Addr   string
+	Weight int
+}
+
+// Hashing selects how keys are distributed among the servers.
+type Hashing int
+
+const (
+	// HashKetama is consistent hashing on a ring of weighted points, compatible
+	// with other ketama clients.
+	HashKetama Hashing = iota
+	// HashRendezvous is weighted highest random weight hashing: only keys of an
+	// added or removed server move, with no ring to build.
+	HashRendezvous
+	// HashJump is jump consistent hashing: the most even distribution with no
+	// memory overhead, but keys move optimally only when servers are added to or
+	// removed from the end of the list.
+	HashJump
+)
+
+var errUnknownHashing = errors.New("memcache: unknown hashing")
+
+// newPicker returns a PickServer function for the servers.
+func newPicker(h Hashing, servers []Server) (func(key string) []string, error) {
+	switch h {
+	case HashKetama:
+		buckets := make([]ketama.Bucket, 0, len(servers))
+		for _, s := range servers {
+			buckets = append(buckets, ketama.Bucket{
+				Label:  s.Addr,
+				Weight: max(s.Weight, 1),
+			})
+		}
+		hash, err := ketama.New(buckets)
+		if err != nil {
+			return nil, err
+		}
+		return func(key string) []string {
+			return hash.HashMultiple(key, len(servers))
+		}, nil
+	case HashRendezvous:
+		return rendezvous(servers), nil
+	case HashJump:
+		return jump(servers), nil
+	}
+	return nil, errUnknownHashing
+}
+
+// maxStackServers is the number of servers up to which the lookups of rendezvous and
+// jump keep their scratch space on the stack, the result is their only allocation.
+const maxStackServers = 64
+
+func rendezvous(servers []Server) func(key string) []string {
+	var (
+		seeds   = make([]uint64, 0, len(servers))
+		weights = make([]float64, 0, len(servers))
+	)
+	for _, s := range servers {
+		seeds = append(seeds, xxhash.Sum64String(s.Addr))
+		weights = append(weights, float64(max(s.Weight, 1)))
+	}
+	return func(key string) []string {
+		type score struct {
+			addr  string
+			score float64
+		}
+		var (
+			h      = xxhash.Sum64String(key)
+			scores = make([]score, 0, maxStackServers)
+		)
+		for i, s := range servers {
+			// -w/ln(u) for u uniform in (0, 1) keeps the share of every server
+			// proportional to its weight.
+			u := (float64(mix64(h^seeds[i])>>11) + 0.5) / (1 << 53)
+			scores = append(scores, score{
+				addr:  s.Addr,
+				score: -weights[i] / math.Log(u),
+			})
+		}
+		slices.SortFunc(scores, func(a, b score) int {
+			switch {
+			case a.score > b.score:
+				return -1
+			case a.score < b.score:
+				return 1
+			}
+			return 0
+		})
+		addrs := make([]string, 0, len(scores))
+		for _, s := range scores {
+			addrs = append(addrs, s.addr)
+		}
+		return addrs
+	}
+}
+
+// jump repeats every server Weight times as a bucket. The next candidates for the key
+// are picked by jumping again over the buckets of the remaining servers. Buckets of a
+// server are adjacent, so a bucket is found by walking the weights instead of keeping
+// the list of the remaining buckets.
+func jump(servers []Server) func(key string) []string {
+	var (
+		weights = make([]int, 0, len(servers))
+		total   int
+	)
+	for _, s := range servers {
+		weights = append(weights, max(s.Weight, 1))
+		total += max(s.Weight, 1)
+	}
+	return func(key string) []string {
+		var (
+			h     = xxhash.Sum64String(key)
+			addrs = make([]string, 0, len(servers))
+			// weights of the remaining servers, the picked ones have none.
+			left = append(make([]int, 0, maxStackServers), weights...)
+		)
+		for n := total; n != 0; h = mix64(h) {
+			b, i := jumpHash(h, n), 0
+			for ; b >= left[i]; i++ {
+				b -= left[i]
+			}
+			addrs = append(addrs, servers[i].Addr)
+			n -= left[i]
+			left[i] = 0
+		}
+		return addrs
+	}
+}
+
+// jumpHash is https://arxiv.org/abs/1406.2294
+func jumpHash(key uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// mix64 is the splitmix64 finalizer.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
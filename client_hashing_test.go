@@ -0,0 +1,121 @@
+package mc_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kinescope/mc"
+	"github.com/stretchr/testify/assert"
+)
+
+func picker(t *testing.T, h mc.Hashing, servers []mc.Server) func(string) []string {
+	t.Helper()
+	o := &mc.Options{
+		Servers: servers,
+		Hashing: h,
+	}
+	cache, err := mc.New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Close()
+	return o.PickServer
+}
+
+func testServers(n int) []mc.Server {
+	s := make([]mc.Server, 0, n)
+	for i := range n {
+		s = append(s, mc.Server{Addr: fmt.Sprintf("10.0.0.%d:11211", i+1)})
+	}
+	return s
+}
+
+var hashings = map[string]mc.Hashing{
+	"ketama":     mc.HashKetama,
+	"rendezvous": mc.HashRendezvous,
+	"jump":       mc.HashJump,
+}
+
+const hashedKeys = 20000
+
+func TestHashingRedistribution(t *testing.T) {
+	for name, h := range hashings {
+		t.Run(name, func(t *testing.T) {
+			var (
+				five = picker(t, h, testServers(5))
+				six  = picker(t, h, testServers(6))
+				four = picker(t, h, testServers(4))
+				// the third server removed.
+				middle = picker(t, h, append(testServers(2), testServers(5)[3:]...))
+			)
+			var added, removed, removedMiddle int
+			for i := range hashedKeys {
+				var (
+					k    = fmt.Sprint("key", i)
+					from = five(k)[0]
+				)
+				if to := six(k)[0]; to != from {
+					added++
+					assert.Equal(t, testServers(6)[5].Addr, to, "keys move to the added server only")
+				}
+				if to := four(k)[0]; to != from {
+					removed++
+					assert.Equal(t, testServers(5)[4].Addr, from, "only keys of the removed server move")
+				}
+				if to := middle(k)[0]; to != from {
+					removedMiddle++
+				}
+			}
+			t.Logf("moved keys: %.3f on add, %.3f on remove, %.3f on remove from the middle",
+				float64(added)/hashedKeys, float64(removed)/hashedKeys, float64(removedMiddle)/hashedKeys)
+			assert.InDelta(t, 1.0/6, float64(added)/hashedKeys, 0.05)
+			assert.InDelta(t, 1.0/5, float64(removed)/hashedKeys, 0.05)
+			if h != mc.HashJump {
+				assert.InDelta(t, 1.0/5, float64(removedMiddle)/hashedKeys, 0.05)
+			}
+		})
+	}
+}
+
+func TestHashingWeights(t *testing.T) {
+	for name, h := range hashings {
+		t.Run(name, func(t *testing.T) {
+			s := testServers(3)
+			s[2].Weight = 2
+			var (
+				pick   = picker(t, h, s)
+				shares = make(map[string]int)
+			)
+			for i := range hashedKeys {
+				addrs := pick(fmt.Sprint("key", i))
+				if i == 0 {
+					assert.ElementsMatch(t, []string{s[0].Addr, s[1].Addr, s[2].Addr}, addrs)
+				}
+				shares[addrs[0]]++
+			}
+			assert.InDelta(t, 0.25, float64(shares[s[0].Addr])/hashedKeys, 0.05)
+			assert.InDelta(t, 0.25, float64(shares[s[1].Addr])/hashedKeys, 0.05)
+			assert.InDelta(t, 0.5, float64(shares[s[2].Addr])/hashedKeys, 0.05)
+		})
+	}
+}
+
+func TestUnknownHashing(t *testing.T) {
+	_, err := mc.New(&mc.Options{
+		Servers: testServers(1),
+		Hashing: mc.Hashing(42),
+	})
+	assert.Error(t, err)
+}
+
+func TestHashingAllocs(t *testing.T) {
+	servers := testServers(10)
+	servers[0].Weight = 100
+	for _, name := range []string{"rendezvous", "jump"} {
+		pick := picker(t, hashings[name], servers)
+		// the candidates are the only allocation.
+		assert.Equal(t, 1.0, testing.AllocsPerRun(100, func() {
+			pick("key")
+		}), name)
+	}
+}
@@ -0,0 +1,124 @@
+package mc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+type response struct {
+	addr   string
+	data   []byte
+	extras []byte
+	cas    uint64
+	// hit is set by sendCoalesced for the keys found, unreachable if it couldn't
+	// connect to the server.
+	hit         bool
+	unreachable bool
+	err         error
+}
+
+// read sends a read request for the key. If HedgeDelay is set, the request is also
+// sent to the next server when the previous one hasn't answered within the delay.
+// Replicated keys fall through to the next replica on a miss.
+func (c *Client) read(ctx context.Context, opcode protocol.Opcode, key string, extras, dst []byte) ([]byte, []byte, uint64, error) {
+	var (
+		n     = 2
+		addrs []string
+	)
+	switch {
+	case c.opts.ReplicationFactor > 1:
+		n = c.opts.ReplicationFactor
+	case c.opts.HedgeDelay <= 0:
+		return c.request(ctx, opcode, key, nil, extras, 0, dst)
+	}
+	if addrs = c.pickAddrs(key); len(addrs) < 2 {
+		return c.request(ctx, opcode, key, nil, extras, 0, dst)
+	}
+	r := c.race(ctx, opcode, key, extras, addrs[:min(n, len(addrs))], c.opts.ReplicationFactor > 1)
+	if span := spanFromContext(ctx); span != nil {
+		span.SetAttribute(AttrServer, r.addr)
+	}
+	if dst != nil && r.err == nil {
+		r.data = append(dst, r.data...)
+	}
+	return r.data, r.extras, r.cas, r.err
+}
+
+// race sends the request to addrs one by one: the next server is tried when the
+// previous one fails, misses if fallThrough is set, or hasn't answered within
+// HedgeDelay. The first hit (or miss without fallThrough) wins and the requests
+// still in flight are aborted.
+func (c *Client) race(ctx context.Context, opcode protocol.Opcode, key string, extras []byte, addrs []string, fallThrough bool) response {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		ch       = make(chan response, len(addrs))
+		next     int
+		pending  int
+		hedge    <-chan time.Time
+		timer    *time.Timer
+		miss     *response
+		failed   response
+		delay    = c.opts.HedgeDelay
+		lastSent = func() bool { return next == len(addrs) }
+	)
+	if delay > 0 {
+		timer = time.NewTimer(delay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+	send := func() {
+		addr := addrs[next]
+		next, pending = next+1, pending+1
+		if timer != nil {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(delay)
+		}
+		go func() {
+			r := response{addr: addr}
+			r.data, r.extras, r.cas, r.err = c.requestAddr(ctx, addr, opcode, key, nil, extras, 0)
+			ch <- r
+		}()
+	}
+	send()
+	for {
+		select {
+		case <-hedge:
+			if !lastSent() {
+				send()
+			}
+			continue
+		case r := <-ch:
+			pending--
+			switch {
+			case r.err == nil:
+				return r
+			case errors.Is(r.err, ErrCacheMiss) && !fallThrough:
+				return r
+			case errors.Is(r.err, ErrCacheMiss):
+				miss = &r
+			default:
+				failed = r
+			}
+		}
+		if pending != 0 {
+			continue
+		}
+		if !lastSent() {
+			send()
+			continue
+		}
+		if miss != nil {
+			return *miss
+		}
+		return failed
+	}
+}
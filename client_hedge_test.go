@@ -0,0 +1,81 @@
+package mc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/kinescope/mc/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedgedGet(t *testing.T) {
+	var (
+		slow = mctest.NewServer()
+		fast = mctest.NewServer()
+	)
+	defer slow.Close()
+	defer fast.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:      []string{slow.Addr(), fast.Addr()},
+		HedgeDelay: 10 * time.Millisecond,
+		PickServer: func(key string) []string {
+			return []string{slow.Addr(), fast.Addr()}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx = context.Background()
+		k   = randSeq(16)
+	)
+	for _, srv := range []*mctest.Server{slow, fast} {
+		replica, err := mc.New(&mc.Options{Addrs: []string{srv.Addr()}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := replica.Set(ctx, &mc.Item{Key: k, Value: []byte(srv.Addr())}); err != nil {
+			t.Fatal(err)
+		}
+		replica.Close()
+	}
+
+	{
+		start := time.Now()
+		if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+			assert.Equal(t, slow.Addr(), string(i.Value), "the first server answers within the delay")
+		}
+		assert.Less(t, time.Since(start), 10*time.Millisecond)
+	}
+
+	slow.SetLatency(200 * time.Millisecond)
+	{
+		start := time.Now()
+		if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+			assert.Equal(t, fast.Addr(), string(i.Value))
+		}
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+	}
+	// the aborted request closes its connection.
+	assert.Eventually(t, func() bool {
+		s := cache.Stats()[slow.Addr()]
+		return s.Ops[protocol.Get] == mc.OpStats{Hits: 1, Errors: 1} && s.OpenConnections == 0
+	}, time.Second, time.Millisecond)
+	slow.SetLatency(0)
+
+	// a failed server is hedged right away.
+	slow.FailNext(protocol.StatusBusy, 1)
+	if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+		assert.Equal(t, fast.Addr(), string(i.Value))
+	}
+	fast.FailNext(protocol.StatusBusy, 1)
+	slow.FailNext(protocol.StatusBusy, 1)
+	_, err = cache.Get(ctx, k)
+	assert.ErrorIs(t, err, mc.ErrBusy)
+}
@@ -0,0 +1,65 @@
+package mc
+
+import (
+	"context"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+// Operation describes a client call passed through Options.Interceptors. Interceptors
+// may modify it before calling the next Invoker, e.g. to rewrite keys.
+type Operation struct {
+	// Name is the Client method, e.g. "Get" or "SetMulti".
+	Name   string
+	Opcode protocol.Opcode
+	// Keys of the key based calls: Get, GetInto, GetTo, SetFrom, GetAndTouch, Touch,
+	// Inc, Dec, Delete, PurgeNamespace (the namespace), GetMulti, GetAndTouchMulti
+	// and DeleteMulti.
+	Keys []string
+	// Items of the storage calls: Set, Add, Replace, CompareAndSwap, Append, Prepend,
+	// SetMulti and AddMulti.
+	Items   []*Item
+	Options []Option
+	Delta   uint64 // Inc, Dec
+	Exp     uint32 // GetAndTouch, Touch, GetAndTouchMulti
+}
+
+// Result holds the outcome of an Operation apart from its error.
+type Result struct {
+	Item  *Item            // Get, GetInto, GetAndTouch
+	Items map[string]*Item // GetMulti, GetAndTouchMulti
+	Errs  map[string]error // SetMulti, AddMulti, DeleteMulti
+	Value uint64           // Inc, Dec
+}
+
+// Invoker performs the operation.
+type Invoker func(ctx context.Context, op *Operation) (*Result, error)
+
+// Interceptor wraps every Client call. It may short-circuit the call by returning
+// without calling next, modify the operation or inspect the result of next.
+// Internal round-trips, e.g. namespace versions, don't go through interceptors.
+type Interceptor func(ctx context.Context, op *Operation, next Invoker) (*Result, error)
+
+func (c *Client) intercepted() bool {
+	return len(c.opts.Interceptors) != 0
+}
+
+// intercept calls invoke through the interceptors, the first one is the outermost.
+// The result is never nil.
+func (c *Client) intercept(ctx context.Context, op *Operation, invoke Invoker) (*Result, error) {
+	next := invoke
+	for i := len(c.opts.Interceptors) - 1; i >= 0; i-- {
+		var (
+			interceptor = c.opts.Interceptors[i]
+			invoke      = next
+		)
+		next = func(ctx context.Context, op *Operation) (*Result, error) {
+			return interceptor(ctx, op, invoke)
+		}
+	}
+	res, err := next(ctx, op)
+	if res == nil {
+		res = &Result{}
+	}
+	return res, err
+}
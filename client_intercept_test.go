@@ -0,0 +1,91 @@
+package mc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptors(t *testing.T) {
+	var (
+		calls  []string
+		prefix = randSeq(8) + ":"
+		quota  = errors.New("quota exceeded")
+	)
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+		Interceptors: []mc.Interceptor{
+			// observe
+			func(ctx context.Context, op *mc.Operation, next mc.Invoker) (*mc.Result, error) {
+				res, err := next(ctx, op)
+				calls = append(calls, op.Name)
+				return res, err
+			},
+			// short-circuit
+			func(ctx context.Context, op *mc.Operation, next mc.Invoker) (*mc.Result, error) {
+				if op.Opcode == protocol.Delete {
+					return nil, quota
+				}
+				return next(ctx, op)
+			},
+			// rewrite keys
+			func(ctx context.Context, op *mc.Operation, next mc.Invoker) (*mc.Result, error) {
+				keys := make([]string, len(op.Keys))
+				for i, k := range op.Keys {
+					keys[i] = prefix + k
+				}
+				op.Keys = keys
+				for _, i := range op.Items {
+					i.Key = prefix + i.Key
+				}
+				res, err := next(ctx, op)
+				if res != nil && res.Item != nil {
+					res.Item.Key = res.Item.Key[len(prefix):]
+				}
+				return res, err
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx = context.Background()
+		k   = randSeq(16)
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}, mc.WithNamespace("ns")); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+		assert.Equal(t, k, i.Key)
+		assert.Equal(t, k, string(i.Value))
+	}
+	if items, err := cache.GetMulti(ctx, k); assert.NoError(t, err) {
+		assert.Contains(t, items, prefix+k)
+	}
+	if v, err := cache.Inc(ctx, k+":n", 2, mc.WithInitial(40)); assert.NoError(t, err) {
+		assert.Equal(t, uint64(40), v)
+	}
+	assert.ErrorIs(t, cache.Delete(ctx, k), quota)
+
+	plain, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if i, err := plain.Get(ctx, prefix+k); assert.NoError(t, err, "namespace lookups aren't intercepted") {
+		assert.Equal(t, k, string(i.Value))
+	}
+	_, err = plain.Get(ctx, k)
+	assert.ErrorIs(t, err, mc.ErrCacheMiss)
+
+	assert.Equal(t, []string{"Set", "Get", "GetMulti", "Inc", "Delete"}, calls)
+}
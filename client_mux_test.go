@@ -0,0 +1,123 @@
+package mc_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiplex(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:                 []string{srv.Addr()},
+		MultiplexConnsPerAddr: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx = context.Background()
+		wg  sync.WaitGroup
+	)
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				key := randSeq(16)
+				if !assert.NoError(t, cache.Set(ctx, &mc.Item{Key: key, Value: []byte(key)})) {
+					return
+				}
+				if i, err := cache.Get(ctx, key); assert.NoError(t, err) {
+					assert.Equal(t, key, string(i.Value))
+				}
+				_, err := cache.Get(ctx, key+"-miss")
+				assert.Equal(t, mc.ErrCacheMiss, err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2, srv.NumConns())
+	if stats, ok := cache.PoolStats()[srv.Addr()]; assert.True(t, ok) {
+		assert.Equal(t, 2, stats.OpenConnections)
+	}
+
+	// multi key operations use the pool.
+	items, err := cache.GetMulti(ctx, "a", "b")
+	if assert.NoError(t, err) {
+		assert.Empty(t, items)
+	}
+	assert.Equal(t, 3, srv.NumConns())
+}
+
+func TestMultiplexTimeout(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:                 []string{srv.Addr()},
+		MultiplexConnsPerAddr: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	srv.SetLatency(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = cache.Get(ctx, "key")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the late response is dropped, the connection keeps serving requests.
+	srv.SetLatency(0)
+	if err := cache.Set(context.Background(), &mc.Item{Key: "key", Value: []byte("value")}); assert.NoError(t, err) {
+		if i, err := cache.Get(context.Background(), "key"); assert.NoError(t, err) {
+			assert.Equal(t, "value", string(i.Value))
+		}
+	}
+	assert.Equal(t, 1, srv.NumConns())
+}
+
+func TestMultiplexBrokenConn(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:                 []string{srv.Addr()},
+		MultiplexConnsPerAddr: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	if err := cache.Set(ctx, &mc.Item{Key: "key", Value: []byte("value")}); !assert.NoError(t, err) {
+		return
+	}
+	srv.Down()
+	_, err = cache.Get(ctx, "key")
+	assert.Error(t, err)
+	srv.Up()
+	assert.Eventually(t, func() bool {
+		_, err := cache.Get(ctx, "key")
+		return err == nil
+	}, time.Second, 10*time.Millisecond, "the connection is redialed")
+
+	cache.Close()
+	_, err = cache.Get(ctx, "key")
+	assert.Equal(t, mc.ErrClientClosed, err)
+	assert.Eventually(t, func() bool {
+		return srv.NumConns() == 0
+	}, time.Second, time.Millisecond)
+}
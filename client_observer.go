@@ -0,0 +1,43 @@
+package mc
+
+import (
+	"time"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+// Observer receives an Event for every request sent by the client: one per
+// single-key operation and one per server batch of GetMulti, SetMulti, AddMulti
+// and DeleteMulti. Gets coalesced by BatchWindow get an event each along with the
+// GetKQ event of their batch, which accounts for the bytes. Observe is called
+// synchronously, so it must be fast and safe for concurrent use.
+type Observer interface {
+	Observe(Event)
+}
+
+// Event describes a completed request to a server.
+type Event struct {
+	Opcode protocol.Opcode
+	// Addr is the last server tried if no server could be connected to.
+	Addr     string
+	Keys     int
+	Sent     int // Bytes written, including headers.
+	Received int // Bytes read, including headers.
+	Latency  time.Duration
+	Err      error
+}
+
+// observe reports the request started at start, conn is nil if it failed to connect.
+func (c *Client) observe(opcode protocol.Opcode, addr string, conn *conn, keys int, start time.Time, err error) {
+	e := Event{
+		Opcode:  opcode,
+		Addr:    addr,
+		Keys:    keys,
+		Latency: time.Since(start),
+		Err:     err,
+	}
+	if conn != nil {
+		e.Sent, e.Received = conn.sent, conn.received
+	}
+	c.opts.Observer.Observe(e)
+}
@@ -0,0 +1,127 @@
+package mc_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/kinescope/mc/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []mc.Event
+}
+
+func (r *recorder) Observe(e mc.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) last() mc.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.events[len(r.events)-1]
+}
+
+type noopObserver struct{}
+
+func (noopObserver) Observe(mc.Event) {}
+
+func TestObserver(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	var rec recorder
+	cache, err := mc.New(&mc.Options{
+		Addrs:    []string{srv.Addr()},
+		Observer: &rec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx   = context.Background()
+		k     = randSeq(16)
+		value = []byte("0123456789")
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: value}); err != nil {
+		t.Fatal(err)
+	}
+	if e := rec.last(); assert.Equal(t, protocol.Opcode(protocol.Set), e.Opcode) {
+		assert.Equal(t, srv.Addr(), e.Addr)
+		assert.Equal(t, 1, e.Keys)
+		assert.Equal(t, 24+8+len(k)+len(value), e.Sent)
+		assert.Equal(t, 24, e.Received)
+		assert.Positive(t, e.Latency)
+		assert.NoError(t, e.Err)
+	}
+	if _, err := cache.Get(ctx, k); err != nil {
+		t.Fatal(err)
+	}
+	if e := rec.last(); assert.Equal(t, protocol.Opcode(protocol.Get), e.Opcode) {
+		assert.Equal(t, 24+len(k), e.Sent)
+		assert.Equal(t, 24+4+len(value), e.Received)
+	}
+	cache.Get(ctx, randSeq(16))
+	if e := rec.last(); assert.Equal(t, protocol.Opcode(protocol.Get), e.Opcode) {
+		assert.ErrorIs(t, e.Err, mc.ErrCacheMiss)
+	}
+
+	if _, err := cache.GetMulti(ctx, k, randSeq(16), randSeq(16)); err != nil {
+		t.Fatal(err)
+	}
+	if e := rec.last(); assert.Equal(t, protocol.Opcode(protocol.GetKQ), e.Opcode) {
+		assert.Equal(t, srv.Addr(), e.Addr)
+		assert.Equal(t, 3, e.Keys)
+		assert.Equal(t, 3*24+len(k)+2*16+24, e.Sent)
+		assert.Equal(t, 24+4+len(k)+len(value)+24, e.Received)
+		assert.NoError(t, e.Err)
+	}
+
+	if _, err := cache.DeleteMulti(ctx, k, randSeq(16)); err != nil {
+		t.Fatal(err)
+	}
+	if e := rec.last(); assert.Equal(t, protocol.Opcode(protocol.DeleteQ), e.Opcode) {
+		assert.Equal(t, 2, e.Keys)
+		assert.Equal(t, 2*24+len(k)+16+24, e.Sent)
+		// KeyNotFound of the missing key and Noop.
+		assert.Equal(t, 2*24, e.Received)
+	}
+
+	srv.Close()
+	cache.Get(ctx, k)
+	if e := rec.last(); assert.Equal(t, protocol.Opcode(protocol.Get), e.Opcode) {
+		assert.Error(t, e.Err)
+	}
+}
+
+func TestObserverAllocs(t *testing.T) {
+	allocs := func(o mc.Observer) float64 {
+		cache, err := mc.New(&mc.Options{
+			Addrs:           testServerAddrs,
+			ConnMaxLifetime: time.Hour,
+			Observer:        o,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cache.Close()
+		var (
+			ctx = context.Background()
+			k   = randSeq(16)
+		)
+		cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)})
+		return testing.AllocsPerRun(100, func() {
+			cache.Get(ctx, k)
+		})
+	}
+	assert.Equal(t, allocs(nil), allocs(noopObserver{}))
+}
@@ -5,13 +5,15 @@ import (
 	"time"
 
 	"github.com/cespare/xxhash/v2"
-	"github.com/dgryski/go-ketama"
+	"github.com/kinescope/mc/proto/cache"
 )
 
 const (
 	DefaultTimeout             = 500 * time.Millisecond
 	DefaultConnMaxLifetime     = 30 * time.Minute
 	DefaultMaxIdleConnsPerAddr = 10
+	DefaultEjectTimeout        = 10 * time.Second
+	DefaultBatchMaxKeys        = 100
 )
 
 var xxHashPool = sync.Pool{
@@ -43,6 +45,58 @@ type Options struct {
 	DialTimeout         time.Duration
 	ConnMaxLifetime     time.Duration
 	MaxIdleConnsPerAddr int
+	// ConnMaxIdleTime closes connections idle for longer, 0 means no limit.
+	ConnMaxIdleTime time.Duration
+	// MaxOpenConnsPerAddr limits connections to a single server, requests over the
+	// limit wait for a connection until their context is done. 0 means no limit.
+	MaxOpenConnsPerAddr int
+	// MaxFailures is the number of consecutive network failures after which a server
+	// is ejected from PickServer results for EjectTimeout, 0 disables ejection.
+	MaxFailures int
+	// EjectTimeout is the interval an ejected server is probed at until it responds.
+	EjectTimeout time.Duration
+	// Observer is notified about every request, nil disables it.
+	Observer Observer
+	// Tracer starts spans for Get, Set, Add, Replace, CompareAndSwap and the multi
+	// operations, nil disables tracing.
+	Tracer Tracer
+	// Interceptors wrap every Client call, the first one is the outermost.
+	Interceptors []Interceptor
+	// Retry resends failed single key requests, nil disables retries.
+	Retry *RetryPolicy
+	// HedgeDelay sends Get to the next PickServer candidate as well if the first one
+	// hasn't answered within the delay, the first answer wins. 0 disables hedging.
+	// Hedged requests aren't retried.
+	HedgeDelay time.Duration
+	// ReplicationFactor writes keys to the first N PickServer candidates, reads fall
+	// through to the next replica on a miss. 0 and 1 disable replication, see also
+	// WithReplicas.
+	ReplicationFactor int
+	// WriteQuorum is the number of replicas a write must succeed on, 0 means
+	// a majority.
+	WriteQuorum int
+	// Servers with weights, overrides Addrs.
+	Servers []Server
+	// Hashing selects the default PickServer, ketama unless set.
+	Hashing Hashing
+	// Discovery looks up the servers when Addrs and Servers are empty, and then
+	// every DiscoveryInterval, see SetServers.
+	Discovery         ServerDiscovery
+	DiscoveryInterval time.Duration
+	// MultiplexConnsPerAddr shares that many connections per server among all single
+	// key requests, which are matched with their responses by Opaque, instead of
+	// checking out a connection per request. Multi key operations still use the
+	// pool. 0 disables multiplexing.
+	MultiplexConnsPerAddr int
+	// BatchWindow collects Gets arriving within the window into a single GetKQ
+	// pipeline per server, sent once the window passes or BatchMaxKeys keys are
+	// collected. Hedged and replicated Gets aren't batched. 0 disables batching.
+	BatchWindow  time.Duration
+	BatchMaxKeys int
+	// PlainTouch makes Touch send the touch command, which doesn't transfer the
+	// value, instead of GAT. Only for clients that don't store items with a scaling
+	// expiration: their envelopes aren't rewritten then.
+	PlainTouch bool
 }
 
 func (o *Options) setDefaults() error {
@@ -58,22 +112,40 @@ func (o *Options) setDefaults() error {
 	if o.MaxIdleConnsPerAddr == 0 {
 		o.MaxIdleConnsPerAddr = DefaultMaxIdleConnsPerAddr
 	}
+	if o.EjectTimeout == 0 {
+		o.EjectTimeout = DefaultEjectTimeout
+	}
+	if o.BatchMaxKeys == 0 {
+		o.BatchMaxKeys = DefaultBatchMaxKeys
+	}
+	if o.DiscoveryInterval == 0 {
+		o.DiscoveryInterval = DefaultDiscoveryInterval
+	}
+	if o.Retry != nil {
+		// the policy may be shared with other clients, defaults go to a copy.
+		retry := *o.Retry
+		retry.setDefaults()
+		o.Retry = &retry
+	}
 
+	if len(o.Servers) != 0 {
+		o.Addrs = make([]string, 0, len(o.Servers))
+		for _, s := range o.Servers {
+			o.Addrs = append(o.Addrs, s.Addr)
+		}
+	}
 	if o.PickServer == nil && len(o.Addrs) != 0 {
-		buckets := make([]ketama.Bucket, 0, len(o.Addrs))
-		for _, s := range o.Addrs {
-			buckets = append(buckets, ketama.Bucket{
-				Label:  s,
-				Weight: 1,
-			})
+		servers := o.Servers
+		if len(servers) == 0 {
+			for _, addr := range o.Addrs {
+				servers = append(servers, Server{Addr: addr, Weight: 1})
+			}
 		}
-		hash, err := ketama.New(buckets)
+		pick, err := newPicker(o.Hashing, servers)
 		if err != nil {
 			return err
 		}
-		o.PickServer = func(key string) []string {
-			return hash.HashMultiple(key, len(o.Addrs))
-		}
+		o.PickServer = pick
 	}
 	return nil
 }
@@ -81,11 +153,13 @@ func (o *Options) setDefaults() error {
 type Option func(c *opts)
 
 type opts struct {
+	replicas          int
 	initial           uint64
 	minUses           uint32
 	namespace         string
 	expiration        uint32
 	scalingExpiration uint32
+	ns                *cache.Namespace
 }
 
 // Inc only
@@ -95,6 +169,14 @@ func WithInitial(v uint64) Option {
 	}
 }
 
+// WithReplicas overrides Options.ReplicationFactor for a Set, Add, Replace,
+// CompareAndSwap, Inc, SetMulti, AddMulti or SetFrom call.
+func WithReplicas(n int) Option {
+	return func(c *opts) {
+		c.replicas = n
+	}
+}
+
 func WithMinUses(number uint32) Option {
 	return func(c *opts) {
 		c.minUses = number
@@ -0,0 +1,104 @@
+package mc
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+// replicas returns the number of servers to write to, n is set by WithReplicas.
+func (c *Client) replicas(n int) int {
+	if n == 0 {
+		return c.opts.ReplicationFactor
+	}
+	return n
+}
+
+func (c *Client) quorum(n int) int {
+	if q := c.opts.WriteQuorum; q > 0 {
+		return min(q, n)
+	}
+	return n/2 + 1
+}
+
+// replicate sends the write to the first n servers for the key and waits for all of
+// them. The write succeeds if it succeeded on a quorum of the servers, the response
+// of the first one is returned then. Deleting or touching a missing key counts as
+// success unless it's missing everywhere. Compare-and-swap is checked against the
+// first server only, the rest of the replicas are overwritten once it succeeds.
+func (c *Client) replicate(ctx context.Context, n int, opcode protocol.Opcode, key string, data, extras []byte, cas uint64) ([]byte, []byte, uint64, error) {
+	if n <= 1 {
+		return c.request(ctx, opcode, key, data, extras, cas, nil)
+	}
+	addrs := c.pickAddrs(key)
+	if len(addrs) < 2 {
+		return c.request(ctx, opcode, key, data, extras, cas, nil)
+	}
+	addrs = addrs[:min(n, len(addrs))]
+	resps := make([]response, len(addrs))
+	if cas != 0 {
+		r := &resps[0]
+		r.addr = addrs[0]
+		if r.data, r.extras, r.cas, r.err = c.requestAddr(ctx, r.addr, opcode, key, data, extras, cas); r.err != nil {
+			return nil, nil, 0, r.err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		if i == 0 && cas != 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(r *response, addr string) {
+			defer wg.Done()
+			r.addr = addr
+			r.data, r.extras, r.cas, r.err = c.requestAddr(ctx, addr, opcode, key, data, extras, 0)
+		}(&resps[i], addr)
+	}
+	wg.Wait()
+
+	var (
+		done   int
+		first  *response
+		missed error
+		failed error
+	)
+	for i := range resps {
+		switch r := &resps[i]; {
+		case r.err == nil:
+			if done++; first == nil {
+				first = r
+			}
+		case missable(opcode) && errors.Is(r.err, ErrCacheMiss):
+			done, missed = done+1, r.err
+		case failed == nil:
+			failed = r.err
+		}
+	}
+	switch {
+	case done < c.quorum(len(addrs)):
+		if failed == nil {
+			failed = missed
+		}
+		return nil, nil, 0, failed
+	case first == nil:
+		return nil, nil, 0, missed
+	}
+	if span := spanFromContext(ctx); span != nil {
+		span.SetAttribute(AttrServer, first.addr)
+	}
+	return first.data, first.extras, first.cas, nil
+}
+
+// missable tells whether a replica missing the key doesn't fail the write: there's
+// nothing to delete or touch there.
+func missable(opcode protocol.Opcode) bool {
+	switch opcode {
+	case protocol.Delete, protocol.DeleteQ, protocol.Touch, protocol.GAT:
+		return true
+	}
+	return false
+}
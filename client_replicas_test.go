@@ -0,0 +1,199 @@
+package mc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/stretchr/testify/assert"
+)
+
+// direct returns clients talking to every server of the cluster alone.
+func direct(t *testing.T, cluster mctest.Cluster) []*mc.Client {
+	t.Helper()
+	clients := make([]*mc.Client, 0, len(cluster))
+	for _, srv := range cluster {
+		cache, err := mc.New(&mc.Options{Addrs: []string{srv.Addr()}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { cache.Close() })
+		clients = append(clients, cache)
+	}
+	return clients
+}
+
+func replicated(t *testing.T, cluster mctest.Cluster, o *mc.Options) *mc.Client {
+	t.Helper()
+	o.Addrs = cluster.Addrs()
+	o.PickServer = func(string) []string {
+		return cluster.Addrs()
+	}
+	cache, err := mc.New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestReplicatedWrites(t *testing.T) {
+	cluster := mctest.NewCluster(3)
+	defer cluster.Close()
+
+	var (
+		ctx     = context.Background()
+		k       = randSeq(16)
+		servers = direct(t, cluster)
+		cache   = replicated(t, cluster, &mc.Options{ReplicationFactor: 2})
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+		t.Fatal(err)
+	}
+	for n, srv := range servers {
+		_, err := srv.Get(ctx, k)
+		assert.Equal(t, n < 2, err == nil, n)
+	}
+
+	// reads fall through to the next replica.
+	cluster[0].Flush()
+	if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+		assert.Equal(t, k, string(i.Value))
+	}
+	if items, err := cache.GetMulti(ctx, k, randSeq(16)); assert.NoError(t, err) {
+		assert.Len(t, items, 1)
+		assert.Contains(t, items, k)
+	}
+
+	// the item exists on the second replica only, Add fails without a quorum.
+	assert.Equal(t, mc.ErrAlreadyExists, cache.Add(ctx, &mc.Item{Key: k}))
+
+	if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+		i.Value = []byte("swapped")
+		if err := cache.CompareAndSwap(ctx, i); assert.NoError(t, err) {
+			for _, srv := range servers[:2] {
+				if i, err := srv.Get(ctx, k); assert.NoError(t, err) {
+					assert.Equal(t, "swapped", string(i.Value))
+				}
+			}
+		}
+	}
+
+	assert.NoError(t, cache.Delete(ctx, k))
+	for _, srv := range servers {
+		_, err := srv.Get(ctx, k)
+		assert.Equal(t, mc.ErrCacheMiss, err)
+	}
+	assert.Equal(t, mc.ErrCacheMiss, cache.Delete(ctx, k))
+
+	if v, err := cache.Inc(ctx, k, 1, mc.WithInitial(5), mc.WithReplicas(3)); assert.NoError(t, err) {
+		assert.Equal(t, uint64(5), v)
+		for _, srv := range servers {
+			if v, err := srv.Inc(ctx, k, 1); assert.NoError(t, err) {
+				assert.Equal(t, uint64(6), v)
+			}
+		}
+	}
+}
+
+func TestReplicatedWriteQuorum(t *testing.T) {
+	cluster := mctest.NewCluster(3)
+	defer cluster.Close()
+
+	var (
+		ctx    = context.Background()
+		k      = randSeq(16)
+		cache  = replicated(t, cluster, &mc.Options{ReplicationFactor: 3})
+		single = replicated(t, cluster, &mc.Options{ReplicationFactor: 3, WriteQuorum: 1})
+	)
+	cluster[0].Close()
+	assert.NoError(t, cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}), "2 of 3")
+	if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+		assert.Equal(t, k, string(i.Value))
+	}
+
+	cluster[1].Close()
+	err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)})
+	var opErr *mc.OpError
+	if assert.ErrorAs(t, err, &opErr) {
+		assert.Equal(t, cluster[0].Addr(), opErr.Addr)
+	}
+	assert.NoError(t, single.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}))
+}
+
+func TestReplicatedMultiWrites(t *testing.T) {
+	cluster := mctest.NewCluster(3)
+	defer cluster.Close()
+
+	var (
+		ctx     = context.Background()
+		k       = randSeq(16)
+		servers = direct(t, cluster)
+		cache   = replicated(t, cluster, &mc.Options{ReplicationFactor: 2})
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+		t.Fatal(err)
+	}
+	if errs, err := cache.DeleteMulti(ctx, k); assert.NoError(t, err) && assert.Empty(t, errs) {
+		_, err := cache.Get(ctx, k)
+		assert.Equal(t, mc.ErrCacheMiss, err)
+	}
+	if errs, err := cache.DeleteMulti(ctx, k); assert.NoError(t, err) {
+		assert.Equal(t, mc.ErrCacheMiss, errs[k])
+	}
+
+	if errs, err := cache.SetMulti(ctx, []*mc.Item{{Key: k, Value: []byte("a")}}); assert.NoError(t, err) && assert.Empty(t, errs) {
+		assert.NoError(t, cache.Append(ctx, &mc.Item{Key: k, Value: []byte("b")}))
+		assert.NoError(t, cache.Touch(ctx, k, 60))
+		for n, srv := range servers {
+			i, err := srv.Get(ctx, k)
+			if n < 2 && assert.NoError(t, err, n) {
+				assert.Equal(t, "ab", string(i.Value))
+			} else if n == 2 {
+				assert.Equal(t, mc.ErrCacheMiss, err)
+			}
+		}
+	}
+
+	// the item exists on the first replica only, there's no quorum to prepend to it.
+	cluster[1].Flush()
+	assert.Equal(t, mc.ErrNotStored, cache.Prepend(ctx, &mc.Item{Key: k, Value: []byte("c")}))
+
+	assert.Error(t, cache.SetFrom(ctx, k, strings.NewReader(k), int64(len(k))))
+	if assert.NoError(t, cache.SetFrom(ctx, k, strings.NewReader(k), int64(len(k)), mc.WithReplicas(1))) {
+		if i, err := servers[0].Get(ctx, k); assert.NoError(t, err) {
+			assert.Equal(t, k, string(i.Value))
+		}
+	}
+}
+
+func TestReplicatedTouch(t *testing.T) {
+	cluster := mctest.NewCluster(3)
+	defer cluster.Close()
+
+	var (
+		ctx     = context.Background()
+		k1, k2  = randSeq(16), randSeq(16)
+		servers = direct(t, cluster)
+		cache   = replicated(t, cluster, &mc.Options{ReplicationFactor: 2})
+	)
+	for _, k := range []string{k1, k2} {
+		if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}, mc.WithExpiration(1, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.NoError(t, cache.Touch(ctx, k1, 60))
+	if items, err := cache.GetAndTouchMulti(ctx, 60, k2); assert.NoError(t, err) {
+		assert.Contains(t, items, k2)
+	}
+	time.Sleep(2 * time.Second)
+	for _, srv := range servers[:2] {
+		for _, k := range []string{k1, k2} {
+			_, err := srv.Get(ctx, k)
+			assert.NoError(t, err, k)
+		}
+	}
+}
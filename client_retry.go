@@ -0,0 +1,90 @@
+package mc
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+const (
+	DefaultRetryAttempts   = 3
+	DefaultRetryBackoff    = 5 * time.Millisecond
+	DefaultRetryMaxBackoff = 100 * time.Millisecond
+)
+
+// RetryPolicy resends requests that failed with a retryable error, see IsRetryable.
+// Busy and temporary failure responses mean the request wasn't executed, so any
+// request is resent. After a broken connection the outcome is unknown and only
+// idempotent requests are resent. A broken connection likely means a restarted
+// server, so the idle connections to it are closed before the next attempt, which
+// dials a new one. Dial failures move on to the next PickServer candidate.
+type RetryPolicy struct {
+	// MaxAttempts including the first one.
+	MaxAttempts int
+	// Backoff is the delay before the second attempt, it doubles with every next
+	// attempt up to MaxBackoff. Delays are randomized by up to a half.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Idempotent reports whether the opcode is safe to resend, nil means IsIdempotent.
+	Idempotent func(protocol.Opcode) bool
+}
+
+// IsIdempotent reports whether sending the opcode twice has the same effect as
+// sending it once: reads, touches, Set and Replace. Compare-and-swap requests are
+// never considered idempotent.
+func IsIdempotent(opcode protocol.Opcode) bool {
+	switch opcode {
+	case protocol.Get, protocol.GetKQ, protocol.GAT, protocol.GATQ, protocol.GATKQ, protocol.Touch,
+		protocol.Set, protocol.SetQ, protocol.Replace, protocol.Noop, protocol.Version:
+		return true
+	}
+	return false
+}
+
+func (p *RetryPolicy) setDefaults() {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = DefaultRetryAttempts
+	}
+	if p.Backoff == 0 {
+		p.Backoff = DefaultRetryBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if p.Idempotent == nil {
+		p.Idempotent = IsIdempotent
+	}
+}
+
+func (p *RetryPolicy) retryable(opcode protocol.Opcode, cas uint64, err error) bool {
+	switch {
+	case errors.Is(err, ErrBusy), errors.Is(err, ErrTemporaryFailure):
+		return true
+	case !IsRetryable(err):
+		return false
+	}
+	return cas == 0 && p.Idempotent(opcode)
+}
+
+// backoff waits before the attempt, false means the ctx would be done before that.
+func (p *RetryPolicy) backoff(ctx context.Context, attempt int) bool {
+	d := p.Backoff << (attempt - 2)
+	if d > p.MaxBackoff || d <= 0 {
+		d = p.MaxBackoff
+	}
+	d -= time.Duration(rand.Int64N(int64(d)/2 + 1))
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,135 @@
+package mc_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/kinescope/mc/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs: []string{srv.Addr()},
+		Retry: &mc.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx = context.Background()
+		k   = randSeq(16)
+	)
+	srv.FailNext(protocol.StatusBusy, 2)
+	assert.NoError(t, cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}))
+
+	srv.FailNext(protocol.StatusTemporaryFailure, 3)
+	_, err = cache.Get(ctx, k)
+	assert.ErrorIs(t, err, mc.ErrTemporaryFailure)
+
+	// busy servers don't execute requests, so they are resent even if not idempotent.
+	srv.FailNext(protocol.StatusBusy, 1)
+	if v, err := cache.Inc(ctx, k+":n", 1, mc.WithInitial(1)); assert.NoError(t, err) {
+		assert.Equal(t, uint64(1), v)
+	}
+
+	// the pooled connection is broken by the restart.
+	srv.Down()
+	srv.Up()
+	if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+		assert.Equal(t, k, string(i.Value))
+	}
+	srv.Down()
+	srv.Up()
+	_, err = cache.Inc(ctx, k+":n", 1)
+	assert.True(t, mc.IsRetryable(err), "the outcome of Inc is unknown")
+
+	if s := cache.Stats()[srv.Addr()]; assert.NotNil(t, s.Ops) {
+		assert.Equal(t, mc.OpStats{Hits: 1, Errors: 2}, s.Ops[protocol.Set])
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs: []string{srv.Addr()},
+		Retry: &mc.RetryPolicy{
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			MaxBackoff:  time.Second,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	srv.FailNext(protocol.StatusBusy, 5)
+	start := time.Now()
+	_, err = cache.Get(ctx, randSeq(16))
+	assert.ErrorIs(t, err, mc.ErrBusy)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestRetryStaleConns(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	retry := &mc.RetryPolicy{
+		MaxAttempts: 2,
+		Backoff:     time.Millisecond,
+	}
+	cache, err := mc.New(&mc.Options{
+		Addrs: []string{srv.Addr()},
+		Retry: retry,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	assert.Equal(t, mc.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}, *retry)
+
+	var (
+		ctx = context.Background()
+		k   = randSeq(16)
+		wg  sync.WaitGroup
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+		t.Fatal(err)
+	}
+	srv.SetLatency(20 * time.Millisecond)
+	for n := 0; n < 5; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Get(ctx, k)
+		}()
+	}
+	wg.Wait()
+	srv.SetLatency(0)
+	if s := cache.PoolStats()[srv.Addr()]; assert.Equal(t, 5, s.Idle) {
+		// every pooled connection is broken by the restart.
+		srv.Down()
+		srv.Up()
+		if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+			assert.Equal(t, k, string(i.Value))
+		}
+	}
+}
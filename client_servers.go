@@ -0,0 +1,54 @@
+package mc
+
+// SetServers replaces the servers keys are distributed among, it's safe to call
+// concurrently with requests. Requests in flight complete on the servers they've
+// picked, connections to the removed servers are closed once released. The default
+// PickServer is rebuilt with Options.Hashing, a custom one must return the new
+// addresses by itself.
+func (c *Client) SetServers(addrs []string) error {
+	servers := make([]Server, 0, len(addrs))
+	for _, addr := range addrs {
+		servers = append(servers, Server{Addr: addr, Weight: 1})
+	}
+	return c.setServers(servers)
+}
+
+func (c *Client) setServers(list []Server) error {
+	if len(list) == 0 {
+		return ErrNoServers
+	}
+	c.pool.mu.Lock()
+	defer c.pool.mu.Unlock()
+	if c.pool.closed.Load() {
+		return ErrClientClosed
+	}
+	var (
+		old = c.pool.servers.Load()
+		s   = &servers{
+			pick:  old.pick,
+			addrs: make(map[string]*addrPool, len(list)),
+		}
+	)
+	if !c.customPick {
+		pick, err := newPicker(c.opts.Hashing, list)
+		if err != nil {
+			return err
+		}
+		s.pick = pick
+	}
+	for _, srv := range list {
+		if ap, ok := old.addrs[srv.Addr]; ok {
+			s.addrs[srv.Addr] = ap
+			continue
+		}
+		s.addrs[srv.Addr] = newAddrPool(c.opts)
+	}
+	c.pool.servers.Store(s)
+	for addr, ap := range old.addrs {
+		if _, ok := s.addrs[addr]; !ok {
+			c.pool.health.remove(&ap.node)
+			c.pool.drain(ap)
+		}
+	}
+	return nil
+}
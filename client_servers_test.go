@@ -0,0 +1,105 @@
+package mc_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetServers(t *testing.T) {
+	cluster := mctest.NewCluster(3)
+	defer cluster.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:           cluster.Addrs()[:1],
+		ConnMaxLifetime: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx  = context.Background()
+		keys = make([]string, 0, 100)
+	)
+	for range 100 {
+		k := randSeq(16)
+		if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+	assert.Equal(t, 1, cluster[0].NumConns())
+
+	if err := cache.SetServers(cluster.Addrs()[1:]); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		return cluster[0].NumConns() == 0
+	}, time.Second, time.Millisecond, "connections to the removed server are closed")
+
+	stats := cache.Stats()
+	assert.Len(t, stats, 2)
+	assert.NotContains(t, stats, cluster[0].Addr())
+
+	items, err := cache.GetMulti(ctx, keys...)
+	if assert.NoError(t, err) {
+		assert.Empty(t, items, "the keys stayed on the removed server")
+	}
+	for _, k := range keys {
+		if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, srv := range cluster[1:] {
+		assert.Positive(t, srv.NumConns(), "keys are spread over the new servers")
+	}
+
+	assert.Equal(t, mc.ErrNoServers, cache.SetServers(nil))
+	cache.Close()
+	assert.Equal(t, mc.ErrClientClosed, cache.SetServers(cluster.Addrs()))
+}
+
+func TestSetServersConcurrently(t *testing.T) {
+	cluster := mctest.NewCluster(3)
+	defer cluster.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs: cluster.Addrs(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+		wg          sync.WaitGroup
+	)
+	defer cancel()
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				k := randSeq(16)
+				assert.NoError(t, cache.Set(context.Background(), &mc.Item{Key: k, Value: []byte(k)}))
+				_, err := cache.Get(context.Background(), k)
+				if err != nil {
+					assert.Equal(t, mc.ErrCacheMiss, err)
+				}
+			}
+		}()
+	}
+	for n := 0; ctx.Err() == nil; n++ {
+		assert.NoError(t, cache.SetServers(cluster.Addrs()[n%2:]))
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+}
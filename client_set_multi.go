@@ -0,0 +1,248 @@
+package mc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+// SetMulti stores the items using quiet commands pipelined in one round-trip per server.
+// Errors of individual keys are returned in the map, stored keys are absent from it.
+// Unlike Set, CAS values of the items are not updated since quiet commands don't
+// respond on success.
+func (c *Client) SetMulti(ctx context.Context, items []*Item, o ...Option) (map[string]error, error) {
+	return c.populateMultiOp(ctx, "SetMulti", protocol.SetQ, items, o)
+}
+
+// AddMulti is SetMulti that stores only the items that don't exist yet, the others
+// get ErrAlreadyExists.
+func (c *Client) AddMulti(ctx context.Context, items []*Item, o ...Option) (map[string]error, error) {
+	return c.populateMultiOp(ctx, "AddMulti", protocol.AddQ, items, o)
+}
+
+func (c *Client) populateMultiOp(ctx context.Context, name string, opcode protocol.Opcode, items []*Item, o []Option) (map[string]error, error) {
+	if !c.intercepted() {
+		return c.populateMulti(ctx, opcode, items, o...)
+	}
+	res, err := c.intercept(ctx, &Operation{Name: name, Opcode: opcode, Items: items, Options: o}, func(ctx context.Context, op *Operation) (*Result, error) {
+		errs, err := c.populateMulti(ctx, op.Opcode, op.Items, op.Options...)
+		return &Result{Errs: errs}, err
+	})
+	return res.Errs, err
+}
+
+// DeleteMulti deletes the keys in one round-trip per server, missing keys get ErrCacheMiss.
+func (c *Client) DeleteMulti(ctx context.Context, keys ...string) (map[string]error, error) {
+	if !c.intercepted() {
+		return c.deleteMulti(ctx, keys)
+	}
+	res, err := c.intercept(ctx, &Operation{Name: "DeleteMulti", Opcode: protocol.DeleteQ, Keys: keys}, func(ctx context.Context, op *Operation) (*Result, error) {
+		errs, err := c.deleteMulti(ctx, op.Keys)
+		return &Result{Errs: errs}, err
+	})
+	return res.Errs, err
+}
+
+func (c *Client) deleteMulti(ctx context.Context, keys []string) (map[string]error, error) {
+	reqs := make([]multiRequest, 0, len(keys))
+	for _, key := range keys {
+		if !checkKey(key) {
+			return nil, ErrMalformedKey
+		}
+		reqs = append(reqs, multiRequest{key: key})
+	}
+	errs := make(map[string]error)
+	if err := c.sendMulti(ctx, protocol.DeleteQ, c.replicas(0), reqs, errs); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+type multiRequest struct {
+	key    string
+	value  []byte
+	extras []byte
+}
+
+func (c *Client) populateMulti(ctx context.Context, opcode protocol.Opcode, items []*Item, o ...Option) (map[string]error, error) {
+	for _, i := range items {
+		if !checkKey(i.Key) {
+			return nil, ErrMalformedKey
+		}
+	}
+	var opt opts
+	for _, fn := range o {
+		fn(&opt)
+	}
+	var (
+		errs = make(map[string]error)
+		reqs = make([]multiRequest, 0, len(items))
+	)
+	for _, i := range items {
+		value, extras, skip, err := c.prepare(ctx, i, &opt)
+		switch {
+		case err != nil:
+			errs[i.Key] = err
+			continue
+		case skip:
+			continue
+		}
+		reqs = append(reqs, multiRequest{
+			key:    i.Key,
+			value:  value,
+			extras: extras,
+		})
+	}
+	if err := c.sendMulti(ctx, opcode, c.replicas(opt.replicas), reqs, errs); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// sendMulti pipelines quiet requests (or Touch, whose successful responses are
+// skipped) followed by Noop to every server. Requests are tagged with their 1-based
+// index as opaque, so that error responses can be matched back to the keys. Every key
+// is sent to its first n servers, with the same quorum rules as replicate.
+func (c *Client) sendMulti(ctx context.Context, opcode protocol.Opcode, n int, reqs []multiRequest, errs map[string]error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	if c.pool.closed.Load() {
+		return ErrClientClosed
+	}
+	var (
+		reqMap = make(map[string][]multiRequest)
+		// replicas of the keys in PickServer order.
+		replicas = make(map[string][]string, len(reqs))
+	)
+	for _, r := range reqs {
+		addrs := c.pickAddrs(r.key)
+		if len(addrs) == 0 {
+			return ErrNoServers
+		}
+		addrs = addrs[:max(1, min(n, len(addrs)))]
+		for _, addr := range addrs {
+			reqMap[addr] = append(reqMap[addr], r)
+		}
+		replicas[r.key] = addrs
+	}
+
+	type result struct {
+		addr   string
+		failed map[string]error
+	}
+	ch := make(chan result, len(reqMap))
+	for addr, reqs := range reqMap {
+		go func(addr string, reqs []multiRequest) {
+			var (
+				conn   *conn
+				err    error
+				start  time.Time
+				failed = make(map[string]error)
+			)
+			if c.opts.Observer != nil {
+				start = time.Now()
+			}
+			if _, span := c.startSpan(ctx, "mc.batch", opcode); span != nil {
+				span.SetAttribute(AttrServer, addr)
+				span.SetAttribute(AttrKeys, len(reqs))
+				defer func() {
+					span.End(err)
+				}()
+			}
+			defer func() {
+				if c.opts.Observer != nil {
+					c.observe(opcode, addr, conn, len(reqs), start, err)
+				}
+				for _, r := range reqs {
+					c.pool.record(addr, opcode, failed[r.key])
+				}
+				for key, err := range failed {
+					failed[key] = opError(opcode, addr, key, err)
+				}
+				ch <- result{addr: addr, failed: failed}
+			}()
+
+			if conn, err = c.pool.getConn(ctx, addr); err != nil {
+				for _, r := range reqs {
+					failed[r.key] = err
+				}
+				return
+			}
+			defer func() {
+				c.pool.condRelease(ctx, conn, err)
+			}()
+			if deadline, ok := ctx.Deadline(); ok {
+				conn.nc.SetDeadline(deadline)
+				defer conn.nc.SetDeadline(time.Time{})
+			}
+			// quiet commands are silent on success, so on a connection failure
+			// the outcome of every key without an error response is unknown.
+			defer func() {
+				if err != nil {
+					for _, r := range reqs {
+						if _, ok := failed[r.key]; !ok {
+							failed[r.key] = err
+						}
+					}
+				}
+			}()
+
+			for n, r := range reqs {
+				if err = conn.writePacket(opcode, uint32(n+1), c.opts.KeyHashFunc(r.key), r.value, r.extras, 0); err != nil {
+					return
+				}
+			}
+			if err = conn.sendPacket(protocol.Noop, nil, nil, nil, 0); err != nil {
+				return
+			}
+			for {
+				packet, e := conn.readPacket()
+				if n := int(packet.Opaque); e != nil && n > 0 && n <= len(reqs) {
+					failed[reqs[n-1].key] = e
+					continue
+				}
+				if err = e; err != nil || packet.Opcode == protocol.Noop {
+					return
+				}
+			}
+		}(addr, reqs)
+	}
+	failed := make(map[string]map[string]error, len(reqMap))
+	for range reqMap {
+		r := <-ch
+		failed[r.addr] = r.failed
+	}
+	for key, addrs := range replicas {
+		var (
+			done   int
+			hit    bool
+			missed error
+			err    error
+		)
+		for _, addr := range addrs {
+			switch e, ok := failed[addr][key]; {
+			case !ok:
+				done, hit = done+1, true
+			case missable(opcode) && errors.Is(e, ErrCacheMiss):
+				done, missed = done+1, e
+			case err == nil:
+				err = e
+			}
+		}
+		switch {
+		case done < c.quorum(len(addrs)):
+			if err == nil {
+				err = missed
+			}
+			errs[key] = err
+		case !hit:
+			errs[key] = missed
+		}
+	}
+	return nil
+}
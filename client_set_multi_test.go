@@ -0,0 +1,164 @@
+package mc_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/kinescope/mc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMulti(t *testing.T) {
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx    = context.Background()
+		keyVal = make(map[string]string)
+		keys   []string
+		items  []*mc.Item
+	)
+	for n := range 20 {
+		var (
+			k = fmt.Sprintf("%s_%d", randSeq(16), n)
+			v = fmt.Sprintf("%s_%d", randSeq(16), n)
+		)
+		items = append(items, &mc.Item{
+			Key:   k,
+			Value: []byte(v),
+		})
+		keyVal[k] = v
+		keys = append(keys, k)
+	}
+	if errs, err := cache.SetMulti(ctx, items); assert.NoError(t, err) && assert.Empty(t, errs) {
+		if list, err := cache.GetMulti(ctx, keys...); assert.NoError(t, err) {
+			if assert.Len(t, list, len(keys)) {
+				for k, v := range keyVal {
+					assert.Equal(t, v, string(list[k].Value))
+				}
+			}
+		}
+	}
+	if _, err := cache.SetMulti(ctx, []*mc.Item{{Key: "bad key"}}); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrMalformedKey, err)
+	}
+}
+
+func TestAddMulti(t *testing.T) {
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx      = context.Background()
+		existing = make(map[string]bool)
+		items    []*mc.Item
+	)
+	for n := range 20 {
+		k := fmt.Sprintf("%s_%d", randSeq(16), n)
+		if n%3 == 0 {
+			if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte("old")}); err != nil {
+				t.Fatal(err)
+			}
+			existing[k] = true
+		}
+		items = append(items, &mc.Item{
+			Key:   k,
+			Value: []byte("new"),
+		})
+	}
+	if errs, err := cache.AddMulti(ctx, items); assert.NoError(t, err) {
+		if assert.Len(t, errs, len(existing)) {
+			for k := range existing {
+				assert.Equal(t, mc.ErrAlreadyExists, errs[k])
+			}
+		}
+		for _, i := range items {
+			expect := "new"
+			if existing[i.Key] {
+				expect = "old"
+			}
+			if v, err := cache.Get(ctx, i.Key); assert.NoError(t, err) {
+				assert.Equal(t, expect, string(v.Value))
+			}
+		}
+	}
+}
+
+func TestDeleteMulti(t *testing.T) {
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx     = context.Background()
+		missing = randSeq(16)
+		keys    = []string{missing}
+	)
+	for n := range 20 {
+		k := fmt.Sprintf("%s_%d", randSeq(16), n)
+		if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+	if errs, err := cache.DeleteMulti(ctx, keys...); assert.NoError(t, err) {
+		assert.Equal(t, map[string]error{missing: mc.ErrCacheMiss}, errs)
+		if list, err := cache.GetMulti(ctx, keys...); assert.NoError(t, err) {
+			assert.Len(t, list, 0)
+		}
+	}
+}
+
+func TestSetMultiOptions(t *testing.T) {
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx   = context.Background()
+		ns    = randSeq(6)
+		keys  []string
+		items []*mc.Item
+	)
+	for n := range 10 {
+		k := fmt.Sprintf("%s_%d", randSeq(16), n)
+		items = append(items, &mc.Item{
+			Key:   k,
+			Value: []byte(k),
+		})
+		keys = append(keys, k)
+	}
+	for n := range 2 {
+		if errs, err := cache.SetMulti(ctx, items, mc.WithNamespace(ns), mc.WithMinUses(2), mc.WithExpiration(60, 10)); assert.NoError(t, err) && assert.Empty(t, errs) {
+			list, err := cache.GetMulti(ctx, keys...)
+			if assert.NoError(t, err) {
+				switch n {
+				case 0:
+					assert.Len(t, list, 0)
+				default:
+					if assert.Len(t, list, len(keys)) {
+						for _, k := range keys {
+							assert.Equal(t, k, string(list[k].Value))
+						}
+					}
+				}
+			}
+		}
+	}
+	if err := cache.PurgeNamespace(ctx, ns); assert.NoError(t, err) {
+		if list, err := cache.GetMulti(ctx, keys...); assert.NoError(t, err) {
+			assert.Len(t, list, 0)
+		}
+	}
+}
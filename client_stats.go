@@ -0,0 +1,79 @@
+package mc
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+// ServerStats describes connections and requests to a single server.
+type ServerStats struct {
+	PoolStats
+
+	Dials        int64 // The total number of connections dialed.
+	DialFailures int64 // The total number of failed dials.
+	ErrorClosed  int64 // The total number of connections closed due to a request error.
+
+	// Ops is keyed by the opcode sent, quiet commands of batch operations are counted
+	// per key.
+	Ops map[protocol.Opcode]OpStats
+}
+
+// OpStats counts responses: Misses are ErrCacheMiss responses, Errors are any other
+// errors including ErrAlreadyExists, ErrNotStored and network failures.
+type OpStats struct {
+	Hits   int64
+	Misses int64
+	Errors int64
+}
+
+type opCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+	errors atomic.Int64
+}
+
+// Stats returns statistics per server address.
+func (c *Client) Stats() map[string]ServerStats {
+	var (
+		addrs = c.pool.servers.Load().addrs
+		stats = make(map[string]ServerStats, len(addrs))
+	)
+	for addr, ap := range addrs {
+		s := ServerStats{
+			PoolStats:    ap.stats(),
+			Dials:        ap.dials.Load(),
+			DialFailures: ap.dialFailures.Load(),
+			ErrorClosed:  ap.errorClosed.Load(),
+			Ops:          make(map[protocol.Opcode]OpStats),
+		}
+		for op := range ap.ops {
+			o := OpStats{
+				Hits:   ap.ops[op].hits.Load(),
+				Misses: ap.ops[op].misses.Load(),
+				Errors: ap.ops[op].errors.Load(),
+			}
+			if o != (OpStats{}) {
+				s.Ops[protocol.Opcode(op)] = o
+			}
+		}
+		stats[addr] = s
+	}
+	return stats
+}
+
+func (p *pool) record(addr string, opcode protocol.Opcode, err error) {
+	ap, ok := p.servers.Load().addrs[addr]
+	if !ok {
+		return
+	}
+	switch op := &ap.ops[opcode]; {
+	case err == nil:
+		op.hits.Add(1)
+	case errors.Is(err, ErrCacheMiss):
+		op.misses.Add(1)
+	default:
+		op.errors.Add(1)
+	}
+}
@@ -0,0 +1,68 @@
+package mc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/kinescope/mc/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	var (
+		srv  = mctest.NewServer()
+		dead = mctest.NewServer()
+	)
+	defer srv.Close()
+	dead.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:           []string{srv.Addr(), dead.Addr()},
+		ConnMaxLifetime: time.Hour,
+		PickServer: func(key string) []string {
+			if key == "dead" {
+				return []string{dead.Addr()}
+			}
+			return []string{srv.Addr()}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx = context.Background()
+		k   = randSeq(16)
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+		t.Fatal(err)
+	}
+	cache.Get(ctx, k)
+	cache.Get(ctx, randSeq(16))
+	cache.Add(ctx, &mc.Item{Key: k})
+	cache.Get(ctx, "dead")
+	cache.GetMulti(ctx, k, randSeq(16), randSeq(16))
+
+	stats := cache.Stats()
+	if s := stats[srv.Addr()]; assert.NotNil(t, s.Ops) {
+		assert.Equal(t, mc.OpStats{Hits: 1}, s.Ops[protocol.Set])
+		assert.Equal(t, mc.OpStats{Hits: 1, Misses: 1}, s.Ops[protocol.Get])
+		assert.Equal(t, mc.OpStats{Errors: 1}, s.Ops[protocol.Add])
+		assert.Equal(t, mc.OpStats{Hits: 1, Misses: 2}, s.Ops[protocol.GetKQ])
+		// Add conflict keeps the connection.
+		assert.Zero(t, s.ErrorClosed)
+		assert.Equal(t, int64(1), s.Dials)
+		assert.Zero(t, s.DialFailures)
+		assert.Equal(t, 1, s.OpenConnections)
+		assert.Equal(t, 1, s.Idle)
+	}
+	if s := stats[dead.Addr()]; assert.NotNil(t, s.Ops) {
+		assert.Equal(t, int64(1), s.Dials)
+		assert.Equal(t, int64(1), s.DialFailures)
+		assert.Zero(t, s.OpenConnections)
+	}
+}
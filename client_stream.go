@@ -0,0 +1,183 @@
+package mc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"time"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+var (
+	errStreamEnvelope = errors.New("memcache: namespaces and scaling expiration need the value in memory")
+	errStreamReplicas = errors.New("memcache: a streamed value can't be written to several replicas")
+	// errStreamAborted closes the connection a request was written to partially.
+	errStreamAborted = errors.New("memcache: stream aborted")
+)
+
+// SetFrom stores size bytes read from r under the key, the value is streamed to the
+// server rather than held in memory. WithNamespace and WithExpiration with a scale
+// aren't supported, as they wrap the value in an envelope. The value is written to
+// the first server for the key only and isn't retried, so SetFrom fails if the key
+// is to be replicated: pass WithReplicas(1) to store it on a single replica.
+func (c *Client) SetFrom(ctx context.Context, key string, r io.Reader, size int64, o ...Option) error {
+	if !c.intercepted() {
+		return c.setFrom(ctx, key, r, size, o)
+	}
+	_, err := c.intercept(ctx, &Operation{Name: "SetFrom", Opcode: protocol.Set, Keys: []string{key}, Options: o}, func(ctx context.Context, op *Operation) (*Result, error) {
+		return nil, c.setFrom(ctx, op.Keys[0], r, size, op.Options)
+	})
+	return err
+}
+
+func (c *Client) setFrom(ctx context.Context, key string, r io.Reader, size int64, o []Option) (err error) {
+	var opt opts
+	for _, fn := range o {
+		fn(&opt)
+	}
+	switch {
+	case len(opt.namespace) != 0, opt.expiration != 0 && opt.scalingExpiration != 0:
+		return errStreamEnvelope
+	case c.replicas(opt.replicas) > 1:
+		return errStreamReplicas
+	case size < 0:
+		return ErrInvalidArguments
+	case size > math.MaxUint32-256:
+		return ErrValueTooLarge
+	}
+	ctx, span := c.startSpan(ctx, "", protocol.Set)
+	if span != nil {
+		span.SetAttribute(AttrValueSize, int(size))
+		defer func() {
+			span.End(err)
+		}()
+	}
+	_, extras, skip, err := c.prepare(ctx, &Item{Key: key}, &opt)
+	if err != nil || skip {
+		return err
+	}
+	return c.stream(ctx, protocol.Set, key, func(conn *conn) (error, error) {
+		srcErr, err := conn.writePacketFrom(protocol.Set, c.opts.KeyHashFunc(key), extras, r, int(size))
+		switch {
+		case srcErr != nil:
+			return srcErr, errStreamAborted
+		case err != nil:
+			return err, err
+		}
+		if err := conn.flush(); err != nil {
+			return err, err
+		}
+		_, err = conn.readPacket()
+		return err, err
+	})
+}
+
+// GetTo writes the value of the key to w, streaming it from the server rather than
+// reading it into memory. Values stored in an envelope are read into memory first.
+func (c *Client) GetTo(ctx context.Context, key string, w io.Writer) error {
+	if !c.intercepted() {
+		return c.getTo(ctx, key, w)
+	}
+	_, err := c.intercept(ctx, &Operation{Name: "GetTo", Opcode: protocol.Get, Keys: []string{key}}, func(ctx context.Context, op *Operation) (*Result, error) {
+		return nil, c.getTo(ctx, op.Keys[0], w)
+	})
+	return err
+}
+
+func (c *Client) getTo(ctx context.Context, key string, w io.Writer) (err error) {
+	var (
+		size int
+		// envelope is unwrapped once the connection is released, as it may need
+		// requests of its own.
+		envelope, extras []byte
+		cas              uint64
+	)
+	ctx, span := c.startSpan(ctx, "", protocol.Get)
+	if span != nil {
+		defer func() {
+			span.SetAttribute(AttrHit, err == nil)
+			span.SetAttribute(AttrValueSize, size)
+			span.End(err)
+		}()
+	}
+	err = c.stream(ctx, protocol.Get, key, func(conn *conn) (error, error) {
+		if err := conn.sendPacket(protocol.Get, c.opts.KeyHashFunc(key), nil, nil, 0); err != nil {
+			return err, err
+		}
+		packet, n, err := conn.readHead()
+		if err != nil {
+			return err, err
+		}
+		if len(packet.Extras) >= 4 && packet.Extras[0] == MagicValue {
+			envelope = make([]byte, n)
+			if _, err := io.ReadFull(conn.r, envelope); err != nil {
+				return checkError(err), checkError(err)
+			}
+			extras, cas = append([]byte(nil), packet.Extras...), packet.CAS
+			return nil, nil
+		}
+		size = n
+		dstErr, err := conn.copyValue(w, n)
+		if dstErr != nil {
+			return dstErr, err
+		}
+		return err, err
+	})
+	if err != nil || envelope == nil {
+		return err
+	}
+	i, _, err := c.unwrap(ctx, key, envelope, extras, cas)
+	if err != nil {
+		return err
+	}
+	size = len(i.Value)
+	_, err = w.Write(i.Value)
+	return err
+}
+
+// stream is exchange for values streamed from or to the caller. fn returns the error
+// for the caller and the one the connection is released with, which differ if the
+// caller's reader or writer failed.
+func (c *Client) stream(ctx context.Context, opcode protocol.Opcode, key string, fn func(conn *conn) (err, connErr error)) error {
+	if err := c.checkRequest(ctx, key); err != nil {
+		return err
+	}
+	var start time.Time
+	if c.opts.Observer != nil {
+		start = time.Now()
+	}
+	conn, addr, err := c.pickServer(ctx, key)
+	if err != nil {
+		if c.opts.Observer != nil {
+			c.observe(opcode, addr, nil, 1, start, err)
+		}
+		if addr != "" {
+			err = opError(opcode, addr, key, err)
+		}
+		return err
+	}
+	if c.opts.Tracer != nil {
+		if span := spanFromContext(ctx); span != nil {
+			span.SetAttribute(AttrServer, addr)
+		}
+	}
+	deadline, ok := ctx.Deadline()
+	if ok {
+		conn.nc.SetDeadline(deadline)
+	}
+	err, connErr := fn(conn)
+	if ok {
+		conn.nc.SetDeadline(time.Time{})
+	}
+	if c.opts.Observer != nil {
+		c.observe(opcode, addr, conn, 1, start, err)
+	}
+	c.pool.record(addr, opcode, connErr)
+	c.pool.condRelease(ctx, conn, connErr)
+	if err == connErr {
+		err = opError(opcode, addr, key, err)
+	}
+	return err
+}
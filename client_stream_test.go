@@ -0,0 +1,107 @@
+package mc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/kinescope/mc/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingWriter fails after n bytes.
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return w.n, errors.New("write failed")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestStream(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:       []string{srv.Addr()},
+		MaxFailures: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx   = context.Background()
+		k     = randSeq(16)
+		value = strings.Repeat(randSeq(1024), 900)
+	)
+	if err := cache.SetFrom(ctx, k, strings.NewReader(value), int64(len(value)), mc.WithExpiration(60, 0)); !assert.NoError(t, err) {
+		return
+	}
+	var buf bytes.Buffer
+	if err := cache.GetTo(ctx, k, &buf); assert.NoError(t, err) {
+		assert.Equal(t, value, buf.String())
+	}
+	if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+		assert.Equal(t, value, string(i.Value))
+	}
+	assert.Equal(t, mc.ErrCacheMiss, cache.GetTo(ctx, randSeq(16), &buf))
+
+	// values in envelopes are unwrapped.
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte("value")}, mc.WithExpiration(60, 10)); assert.NoError(t, err) {
+		buf.Reset()
+		if err := cache.GetTo(ctx, k, &buf); assert.NoError(t, err) {
+			assert.Equal(t, "value", buf.String())
+		}
+	}
+	assert.ErrorIs(t, cache.SetFrom(ctx, k, bytes.NewReader(make([]byte, 2<<20)), 2<<20), mc.ErrValueTooLarge)
+	assert.ErrorContains(t, cache.SetFrom(ctx, k, strings.NewReader("value"), 5, mc.WithNamespace("ns")), "in memory")
+
+	// failures of the caller's reader and writer don't count against the server.
+	err = cache.SetFrom(ctx, k, strings.NewReader(value), int64(len(value))+1)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	if err := cache.SetFrom(ctx, k, strings.NewReader(value), int64(len(value))); assert.NoError(t, err) {
+		err := cache.GetTo(ctx, k, &failingWriter{n: 10_000})
+		assert.EqualError(t, err, "write failed")
+		buf.Reset()
+		if err := cache.GetTo(ctx, k, &buf); assert.NoError(t, err) {
+			assert.Equal(t, value, buf.String())
+		}
+	}
+	stats := cache.Stats()[srv.Addr()]
+	assert.Zero(t, stats.DialFailures)
+	assert.Equal(t, int64(2), stats.ErrorClosed, "too large and partially written requests")
+	assert.Equal(t, mc.OpStats{Hits: 3, Errors: 2}, stats.Ops[protocol.Set])
+}
+
+func TestStreamDeadline(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs: []string{srv.Addr()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	if err := cache.SetFrom(context.Background(), "key", strings.NewReader("value"), 5); err != nil {
+		t.Fatal(err)
+	}
+	srv.SetLatency(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, cache.GetTo(ctx, "key", io.Discard))
+}
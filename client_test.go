@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/protocol"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -227,3 +228,266 @@ func TestDeadline(t *testing.T) {
 		}
 	}
 }
+
+func TestReplace(t *testing.T) {
+	ctx := context.Background()
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		k = randSeq(16)
+		v = randSeq(24)
+	)
+	if err := cache.Replace(ctx, &mc.Item{Key: k, Value: []byte(v)}); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrCacheMiss, err)
+	}
+	err = cache.Set(ctx, &mc.Item{
+		Key:   k,
+		Value: []byte(randSeq(24)),
+	})
+	if assert.NoError(t, err) {
+		if err := cache.Replace(ctx, &mc.Item{Key: k, Value: []byte(v)}, mc.WithNamespace(randSeq(6))); assert.NoError(t, err) {
+			if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+				assert.Equal(t, v, string(i.Value))
+			}
+		}
+	}
+}
+
+func TestAppendPrepend(t *testing.T) {
+	ctx := context.Background()
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := randSeq(16)
+	if err := cache.Append(ctx, &mc.Item{Key: k, Value: []byte("c")}); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrNotStored, err)
+	}
+	err = cache.Set(ctx, &mc.Item{
+		Key:   k,
+		Value: []byte("b"),
+	})
+	if assert.NoError(t, err) {
+		i := &mc.Item{Key: k, Value: []byte("c")}
+		if err := cache.Append(ctx, i); assert.NoError(t, err) {
+			if err := cache.Prepend(ctx, &mc.Item{Key: k, Value: []byte("a")}); assert.NoError(t, err) {
+				if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+					assert.Equal(t, "abc", string(i.Value))
+				}
+			}
+			// CAS returned by the append is stale by now.
+			i.Value = []byte("d")
+			if err := cache.CompareAndSwap(ctx, i); assert.Error(t, err) {
+				assert.Equal(t, mc.ErrCASConflict, err)
+			}
+			// CAS of an item read before is honored.
+			if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+				i.Value = []byte("d")
+				if err := cache.Append(ctx, i); assert.NoError(t, err) {
+					if err := cache.Append(ctx, &mc.Item{Key: k, Value: []byte("e")}); assert.NoError(t, err) {
+						if err := cache.Append(ctx, i); assert.Error(t, err) {
+							assert.Equal(t, mc.ErrCASConflict, err)
+						}
+					}
+				}
+			}
+			if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+				assert.Equal(t, "abcde", string(i.Value))
+			}
+		}
+	}
+
+	k = randSeq(16)
+	err = cache.Set(ctx, &mc.Item{
+		Key:   k,
+		Value: []byte("a"),
+	}, mc.WithNamespace(randSeq(6)))
+	if assert.NoError(t, err) {
+		if err := cache.Append(ctx, &mc.Item{Key: k, Value: []byte("b")}); assert.Error(t, err) {
+			assert.Equal(t, mc.ErrEnvelopedValue, err)
+		}
+		if err := cache.Prepend(ctx, &mc.Item{Key: k, Value: []byte("b")}); assert.Error(t, err) {
+			assert.Equal(t, mc.ErrEnvelopedValue, err)
+		}
+	}
+}
+
+func TestTouch(t *testing.T) {
+	ctx := context.Background()
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Touch(ctx, randSeq(16), 10); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrCacheMiss, err)
+	}
+	var (
+		plain  = randSeq(16)
+		scaled = randSeq(16)
+		v      = randSeq(24)
+	)
+	err = cache.Set(ctx, &mc.Item{
+		Key:   plain,
+		Value: []byte(v),
+	}, mc.WithExpiration(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cache.Set(ctx, &mc.Item{
+		Key:   scaled,
+		Value: []byte(v),
+	}, mc.WithExpiration(1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.NoError(t, cache.Touch(ctx, plain, 10)) && assert.NoError(t, cache.Touch(ctx, scaled, 10)) {
+		time.Sleep(3 * time.Second)
+		for _, k := range []string{plain, scaled} {
+			if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+				assert.Equal(t, v, string(i.Value))
+			}
+		}
+	}
+
+	// the touch command doesn't transfer the value.
+	plainTouch, err := mc.New(&mc.Options{
+		Addrs:      testServerAddrs,
+		PlainTouch: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plainTouch.Close()
+	if assert.NoError(t, plainTouch.Touch(ctx, plain, 10)) {
+		var touched int64
+		for _, s := range plainTouch.Stats() {
+			touched += s.Ops[protocol.Touch].Hits
+		}
+		assert.Equal(t, int64(1), touched)
+	}
+}
+
+func TestGetAndTouch(t *testing.T) {
+	ctx := context.Background()
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		k = randSeq(16)
+		v = randSeq(24)
+	)
+	if _, err := cache.GetAndTouch(ctx, k, 10); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrCacheMiss, err)
+	}
+	err = cache.Set(ctx, &mc.Item{
+		Key:   k,
+		Value: []byte(v),
+	}, mc.WithExpiration(1, 1), mc.WithNamespace(randSeq(6)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := cache.GetAndTouch(ctx, k, 10); assert.NoError(t, err) {
+		assert.Equal(t, v, string(i.Value))
+		time.Sleep(3 * time.Second)
+		if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+			assert.Equal(t, v, string(i.Value))
+		}
+	}
+}
+
+func TestGetAndTouchExpired(t *testing.T) {
+	ctx := context.Background()
+	cache, err := mc.New(&mc.Options{
+		Addrs: testServerAddrs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := randSeq(16)
+	err = cache.Set(ctx, &mc.Item{
+		Key:   k,
+		Value: []byte(randSeq(24)),
+	}, mc.WithExpiration(1, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2100 * time.Millisecond)
+	if _, err := cache.GetAndTouch(ctx, k, 60); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrCacheMiss, err)
+	}
+	// the expired envelope is not kept alive past its scale window.
+	time.Sleep(3500 * time.Millisecond)
+	for n := 0; n < 2; n++ {
+		if _, err := cache.Get(ctx, k); assert.Error(t, err) {
+			assert.Equal(t, mc.ErrCacheMiss, err)
+		}
+	}
+}
+
+func TestGetInto(t *testing.T) {
+	for name, o := range map[string]mc.Options{
+		"Pool":      {},
+		"Multiplex": {MultiplexConnsPerAddr: 1},
+		"Batch":     {BatchWindow: time.Millisecond},
+		"Hedge":     {HedgeDelay: time.Millisecond},
+	} {
+		t.Run(name, func(t *testing.T) {
+			o.Addrs = testServerAddrs
+			cache, err := mc.New(&o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cache.Close()
+
+			var (
+				ctx   = context.Background()
+				k     = randSeq(16)
+				large = randSeq(100 << 10)
+			)
+			if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte("value")}); err != nil {
+				t.Fatal(err)
+			}
+			i, err := cache.Get(ctx, k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v, err := cache.GetInto(ctx, k, []byte("prefix:")); assert.NoError(t, err) {
+				assert.Equal(t, "prefix:value", string(v))
+			}
+			v, err := cache.GetInto(ctx, randSeq(16), []byte("prefix:"))
+			if assert.Equal(t, mc.ErrCacheMiss, err) {
+				assert.Equal(t, "prefix:", string(v))
+			}
+
+			// values larger than the connection buffers.
+			if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(large)}); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, 0, 16)
+			if v, err := cache.GetInto(ctx, k, buf); assert.NoError(t, err) {
+				assert.Equal(t, large, string(v))
+			}
+
+			// values in envelopes are unwrapped.
+			if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte("value")}, mc.WithExpiration(60, 10)); err != nil {
+				t.Fatal(err)
+			}
+			if v, err := cache.GetInto(ctx, k, nil); assert.NoError(t, err) {
+				assert.Equal(t, "value", string(v))
+			}
+			// values of Get don't share buffers with the reads that followed.
+			assert.Equal(t, "value", string(i.Value))
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package mc
+
+import (
+	"context"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+// Tracer starts spans for client calls. It's small enough to be adapted to
+// OpenTelemetry or any other tracing library, see README.
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx, if any.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced call, End is called once with the error of the call.
+type Span interface {
+	SetAttribute(key string, value any)
+	End(err error)
+}
+
+// Span attributes.
+const (
+	AttrOpcode    = "mc.opcode"      // string
+	AttrServer    = "server.address" // string
+	AttrKeys      = "mc.keys"        // int
+	AttrHit       = "mc.hit"         // bool, int number of hits for batches
+	AttrValueSize = "mc.value_size"  // int
+	AttrNamespace = "mc.namespace"   // string, the key namespace versions are stored at
+	AttrEnvelope  = "mc.envelope"    // bool, the value is wrapped into MagicValue envelope
+)
+
+type spanKey struct{}
+
+// startSpan returns a nil span when tracing is disabled, the span is named after
+// the opcode if name is empty. The span is kept in ctx, so that request sets the
+// address of the server it talked to.
+func (c *Client) startSpan(ctx context.Context, name string, opcode protocol.Opcode) (context.Context, Span) {
+	if c.opts.Tracer == nil {
+		return ctx, nil
+	}
+	if name == "" {
+		name = "mc." + opcode.String()
+	}
+	ctx, span := c.opts.Tracer.Start(ctx, name)
+	span.SetAttribute(AttrOpcode, opcode.String())
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func spanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanKey{}).(Span)
+	return span
+}
@@ -0,0 +1,163 @@
+package mc_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/stretchr/testify/assert"
+)
+
+type tracer struct {
+	mu    sync.Mutex
+	spans []*span
+}
+
+type span struct {
+	name   string
+	parent *span
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+type parentKey struct{}
+
+func (t *tracer) Start(ctx context.Context, name string) (context.Context, mc.Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &span{
+		name:  name,
+		attrs: make(map[string]any),
+	}
+	s.parent, _ = ctx.Value(parentKey{}).(*span)
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, parentKey{}, s), s
+}
+
+func (t *tracer) reset() []*span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := t.spans
+	t.spans = nil
+	return spans
+}
+
+func (s *span) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *span) End(err error)                      { s.err, s.ended = err, true }
+
+func TestTracing(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	var tr tracer
+	cache, err := mc.New(&mc.Options{
+		Addrs:  []string{srv.Addr()},
+		Tracer: &tr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx   = context.Background()
+		k     = randSeq(16)
+		value = []byte("0123456789")
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: value}, mc.WithNamespace("ns"), mc.WithMinUses(1)); err != nil {
+		t.Fatal(err)
+	}
+	if spans := tr.reset(); assert.Len(t, spans, 3) {
+		set, uses, ns := spans[0], spans[1], spans[2]
+		assert.Equal(t, "mc.set", set.name)
+		assert.Equal(t, "set", set.attrs[mc.AttrOpcode])
+		assert.Equal(t, srv.Addr(), set.attrs[mc.AttrServer])
+		assert.Equal(t, true, set.attrs[mc.AttrEnvelope])
+		assert.NotEmpty(t, set.attrs[mc.AttrNamespace])
+		assert.Greater(t, set.attrs[mc.AttrValueSize], len(value))
+		assert.True(t, set.ended)
+		assert.NoError(t, set.err)
+
+		assert.Equal(t, "mc.min_uses", uses.name)
+		assert.Equal(t, set, uses.parent)
+		assert.Equal(t, srv.Addr(), uses.attrs[mc.AttrServer])
+		assert.Equal(t, "mc.ns_version", ns.name)
+		assert.Equal(t, set, ns.parent)
+		assert.Equal(t, set.attrs[mc.AttrNamespace], ns.attrs[mc.AttrNamespace])
+	}
+
+	if _, err := cache.Get(ctx, k); err != nil {
+		t.Fatal(err)
+	}
+	if spans := tr.reset(); assert.Len(t, spans, 2) {
+		get, ns := spans[0], spans[1]
+		assert.Equal(t, "mc.get", get.name)
+		assert.Equal(t, srv.Addr(), get.attrs[mc.AttrServer])
+		assert.Equal(t, true, get.attrs[mc.AttrHit])
+		assert.Equal(t, true, get.attrs[mc.AttrEnvelope])
+		assert.Equal(t, len(value), get.attrs[mc.AttrValueSize])
+		assert.Equal(t, "mc.ns_version", ns.name)
+		assert.Equal(t, get, ns.parent)
+	}
+
+	cache.Get(ctx, randSeq(16))
+	if spans := tr.reset(); assert.Len(t, spans, 1) {
+		assert.Equal(t, false, spans[0].attrs[mc.AttrHit])
+		assert.ErrorIs(t, spans[0].err, mc.ErrCacheMiss)
+		assert.Nil(t, spans[0].attrs[mc.AttrEnvelope])
+	}
+
+	if _, err := cache.GetMulti(ctx, k, randSeq(16)); err != nil {
+		t.Fatal(err)
+	}
+	if spans := tr.reset(); assert.Len(t, spans, 3) {
+		multi, batch, ns := spans[0], spans[1], spans[2]
+		assert.Equal(t, "mc.get_multi", multi.name)
+		assert.Equal(t, 2, multi.attrs[mc.AttrKeys])
+		assert.Equal(t, 1, multi.attrs[mc.AttrHit])
+		assert.Equal(t, "mc.batch", batch.name)
+		assert.Equal(t, multi, batch.parent)
+		assert.Equal(t, srv.Addr(), batch.attrs[mc.AttrServer])
+		assert.Equal(t, 2, batch.attrs[mc.AttrKeys])
+		assert.Equal(t, "mc.ns_version", ns.name)
+		assert.Equal(t, batch, ns.parent)
+	}
+}
+
+func TestTracingScalingExpiration(t *testing.T) {
+	var tr tracer
+	cache, err := mc.New(&mc.Options{
+		Addrs:  testServerAddrs,
+		Tracer: &tr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx = context.Background()
+		k   = randSeq(16)
+	)
+	// Until is in the past right away, so the next Get takes the :es lock.
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}, mc.WithExpiration(1, 10)); err != nil {
+		t.Fatal(err)
+	}
+	tr.reset()
+	for {
+		if _, err := cache.Get(ctx, k); err == mc.ErrCacheMiss {
+			break
+		}
+		tr.reset()
+	}
+	if spans := tr.reset(); assert.Len(t, spans, 3) {
+		get, es, add := spans[0], spans[1], spans[2]
+		assert.Equal(t, "mc.scaling_expiration", es.name)
+		assert.Equal(t, get, es.parent)
+		assert.Equal(t, "mc.add", add.name)
+		assert.Equal(t, es, add.parent)
+	}
+}
@@ -2,12 +2,27 @@ package mc_test
 
 import (
 	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kinescope/mc/mctest"
 )
 
-var testServerAddrs = []string{
-	"127.0.0.1:11211",
-	"127.0.0.1:11212",
-	"127.0.0.1:11213",
+// testServerAddrs points at in-process mctest servers unless MEMCACHED_ADDRS
+// (comma separated) is set, in which case real memcached instances are used.
+var testServerAddrs []string
+
+func TestMain(m *testing.M) {
+	if addrs := os.Getenv("MEMCACHED_ADDRS"); addrs != "" {
+		testServerAddrs = strings.Split(addrs, ",")
+		os.Exit(m.Run())
+	}
+	cluster := mctest.NewCluster(3)
+	testServerAddrs = cluster.Addrs()
+	code := m.Run()
+	cluster.Close()
+	os.Exit(code)
 }
 
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
@@ -1,6 +1,8 @@
 package mc
 
 import (
+	"bufio"
+	"io"
 	"net"
 	"time"
 
@@ -14,6 +16,8 @@ func openConn(addr string, timeout time.Duration) (*conn, error) {
 	}
 	return &conn{
 		nc:          c,
+		r:           bufio.NewReader(c),
+		w:           bufio.NewWriter(c),
 		addr:        addr,
 		connectedAt: time.Now(),
 	}, nil
@@ -21,12 +25,37 @@ func openConn(addr string, timeout time.Duration) (*conn, error) {
 
 type conn struct {
 	nc          net.Conn
+	r           *bufio.Reader
+	w           *bufio.Writer
 	addr        string
 	packet      protocol.Packet
 	connectedAt time.Time
+	idleSince   time.Time
+	// pool the connection is returned to, nil if it isn't pooled.
+	pool *addrPool
+	// bytes since the connection was handed out, for Observer.
+	sent, received int
+	// vec is the backing array of bufs, values too large for w are written along
+	// with the header in a single writev.
+	vec  [2][]byte
+	bufs net.Buffers
 }
 
 func (c *conn) sendPacket(opcode protocol.Opcode, key, data, extras []byte, cas uint64) error {
+	return c.sendPacketOpaque(opcode, 0, key, data, extras, cas)
+}
+
+// sendPacketOpaque tags the request with opaque, which the server copies into the
+// response, so errors of quiet commands can be told apart.
+func (c *conn) sendPacketOpaque(opcode protocol.Opcode, opaque uint32, key, data, extras []byte, cas uint64) error {
+	if err := c.writePacket(opcode, opaque, key, data, extras, cas); err != nil {
+		return err
+	}
+	return c.flush()
+}
+
+// writePacket buffers the request, pipelines flush after the last one.
+func (c *conn) writePacket(opcode protocol.Opcode, opaque uint32, key, data, extras []byte, cas uint64) error {
 	c.packet.Reset()
 	{
 		c.packet.CAS = cas
@@ -34,14 +63,121 @@ func (c *conn) sendPacket(opcode protocol.Opcode, key, data, extras []byte, cas
 		c.packet.Data = data
 		c.packet.Extras = extras
 		c.packet.Opcode = opcode
+		c.packet.Opaque = opaque
 	}
-	return checkError(c.packet.Write(c.nc))
+	header := c.packet.Header(len(data))
+	if len(header)+len(data) <= c.w.Available() {
+		c.w.Write(header)
+		c.w.Write(data)
+	} else {
+		if err := c.w.Flush(); err != nil {
+			return checkError(err)
+		}
+		c.vec = [2][]byte{header, data}
+		c.bufs = c.vec[:]
+		_, err := c.bufs.WriteTo(c.nc)
+		c.vec = [2][]byte{}
+		if err != nil {
+			return checkError(err)
+		}
+	}
+	c.sent += 24 + len(extras) + len(key) + len(data)
+	return nil
 }
 
+// writePacketFrom buffers the request with a value of size bytes copied from r,
+// srcErr is set if r failed or ended early.
+func (c *conn) writePacketFrom(opcode protocol.Opcode, key, extras []byte, r io.Reader, size int) (srcErr, err error) {
+	c.packet.Reset()
+	{
+		c.packet.Key = key
+		c.packet.Extras = extras
+		c.packet.Opcode = opcode
+	}
+	c.w.Write(c.packet.Header(size))
+	for left := size; left > 0; {
+		if c.w.Available() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return nil, checkError(err)
+			}
+		}
+		buf := c.w.AvailableBuffer()[:min(c.w.Available(), left)]
+		n, err := r.Read(buf)
+		c.w.Write(buf[:n])
+		switch left -= n; {
+		case left == 0:
+		case err == io.EOF:
+			return io.ErrUnexpectedEOF, nil
+		case err != nil:
+			return err, nil
+		}
+	}
+	c.sent += 24 + len(extras) + len(key) + size
+	return nil, nil
+}
+
+func (c *conn) flush() error {
+	if err := c.w.Flush(); err != nil {
+		return checkError(err)
+	}
+	return nil
+}
+
+// readPacket returns the packet even on error: Opcode and Opaque are set
+// if the server responded with an error status.
 func (c *conn) readPacket() (*protocol.Packet, error) {
 	c.packet.Reset()
-	if err := c.packet.Read(c.nc); err != nil {
-		return nil, checkError(err)
+	return c.readResult(c.packet.Read(c.r))
+}
+
+// readPacketInto is readPacket appending the value to dst, see Packet.ReadInto.
+func (c *conn) readPacketInto(dst []byte) (*protocol.Packet, error) {
+	c.packet.Reset()
+	n := len(dst)
+	p, err := c.readResult(c.packet.ReadInto(c.r, dst))
+	if err == nil {
+		c.received -= n
+		// extras are moved past the value, so that they outlive the connection.
+		p.Extras = append(p.Data[len(p.Data):], p.Extras...)
+	}
+	return p, err
+}
+
+// readHead reads the response up to the value, see Packet.ReadHead.
+func (c *conn) readHead() (*protocol.Packet, int, error) {
+	c.packet.Reset()
+	valueLen, err := c.packet.ReadHead(c.r)
+	if _, err := c.readResult(err); err != nil {
+		return &c.packet, 0, err
+	}
+	c.received += valueLen
+	return &c.packet, valueLen, nil
+}
+
+// copyValue copies the value of n bytes left by readHead to w. If w fails the rest
+// of the value is skipped, so that the connection can be reused.
+func (c *conn) copyValue(w io.Writer, n int) (dstErr, err error) {
+	for n > 0 {
+		buf, err := c.r.Peek(min(n, c.r.Size()))
+		if err != nil {
+			return nil, checkError(err)
+		}
+		if _, dstErr = w.Write(buf); dstErr != nil {
+			_, err = c.r.Discard(n)
+			return dstErr, checkError(err)
+		}
+		c.r.Discard(len(buf))
+		n -= len(buf)
+	}
+	return nil, nil
+}
+
+func (c *conn) readResult(err error) (*protocol.Packet, error) {
+	if _, ok := err.(protocol.Status); ok || err == nil {
+		c.received += 24 + len(c.packet.Extras) + len(c.packet.Key) + len(c.packet.Data)
+	}
+	if err != nil {
+		return &c.packet, checkError(err)
 	}
 	return &c.packet, nil
 }
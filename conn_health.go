@@ -0,0 +1,156 @@
+package mc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+// health counts consecutive failures per server. A server that failed maxFailures
+// times in a row is ejected: it's skipped by pickAddrs until a background probe
+// gets a response from it.
+type health struct {
+	ejected      atomic.Int32
+	maxFailures  int32
+	dialTimeout  time.Duration
+	ejectTimeout time.Duration
+	done         chan struct{}
+}
+
+// node is the health of a single server.
+type node struct {
+	failures atomic.Int32
+	ejected  atomic.Bool
+	// removed by SetServers, its probe stops.
+	removed atomic.Bool
+}
+
+func newHealth(o *Options) *health {
+	return &health{
+		maxFailures:  int32(o.MaxFailures),
+		dialTimeout:  o.DialTimeout,
+		ejectTimeout: o.EjectTimeout,
+		done:         make(chan struct{}),
+	}
+}
+
+func (h *health) enabled() bool {
+	return h != nil && h.maxFailures > 0
+}
+
+// report is called with the outcome of every dial and request.
+func (h *health) report(addr string, n *node, err error) {
+	if !h.enabled() || n == nil {
+		return
+	}
+	if !isServerFailure(err) {
+		n.failures.Store(0)
+		return
+	}
+	if n.failures.Add(1) >= h.maxFailures && n.ejected.CompareAndSwap(false, true) {
+		h.ejected.Add(1)
+		go h.probe(addr, n)
+	}
+}
+
+// filter drops ejected servers keeping the order, so for the ketama ring keys of
+// an ejected server move to the next one as if it was removed from the ring. If
+// all of the servers are ejected they are tried anyway.
+func (h *health) filter(s *servers, addrs []string) []string {
+	if !h.enabled() || h.ejected.Load() == 0 {
+		return addrs
+	}
+	alive := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if ap, ok := s.addrs[addr]; !ok || !ap.node.ejected.Load() {
+			alive = append(alive, addr)
+		}
+	}
+	if len(alive) == 0 {
+		return addrs
+	}
+	return alive
+}
+
+func (h *health) probe(addr string, n *node) {
+	for {
+		select {
+		case <-time.After(h.ejectTimeout):
+		case <-h.done:
+			return
+		}
+		if n.removed.Load() {
+			return
+		}
+		if err := ping(addr, h.dialTimeout); err == nil {
+			n.failures.Store(0)
+			h.readmit(n)
+			return
+		}
+	}
+}
+
+func (h *health) readmit(n *node) {
+	if n.ejected.CompareAndSwap(true, false) {
+		h.ejected.Add(-1)
+	}
+}
+
+// remove forgets the server removed by SetServers.
+func (h *health) remove(n *node) {
+	n.removed.Store(true)
+	h.readmit(n)
+}
+
+func (h *health) stop() {
+	close(h.done)
+}
+
+func ping(addr string, timeout time.Duration) error {
+	conn, err := openConn(addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+	conn.nc.SetDeadline(time.Now().Add(timeout))
+	if err := conn.sendPacket(protocol.Version, nil, nil, nil, 0); err != nil {
+		return err
+	}
+	_, err = conn.readPacket()
+	return err
+}
+
+// healthError returns err as reported to health: a timeout of the connection
+// deadline, which requests inherit from ctx, is the caller's error once ctx is done
+// or its deadline has passed.
+func healthError(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// isServerFailure tells network failures from responses of a healthy server. Errors
+// of the caller's context satisfy net.Error too, they are not failures.
+func isServerFailure(err error) bool {
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &netErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+	return false
+}
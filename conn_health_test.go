@@ -0,0 +1,115 @@
+package mc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEjectFailingServer(t *testing.T) {
+	cluster := mctest.NewCluster(2)
+	defer cluster.Close()
+
+	opts := &mc.Options{
+		Addrs:        cluster.Addrs(),
+		MaxFailures:  2,
+		EjectTimeout: 100 * time.Millisecond,
+	}
+	cache, err := mc.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx = context.Background()
+		k   string
+	)
+	for k = randSeq(16); opts.PickServer(k)[0] != cluster[0].Addr(); k = randSeq(16) {
+	}
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster[0].Down()
+	for range 2 {
+		_, err := cache.Get(ctx, k)
+		assert.Error(t, err)
+	}
+	// ejected: the key moved to the other server, which doesn't have it.
+	if _, err := cache.Get(ctx, k); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrCacheMiss, err)
+	}
+
+	cluster[0].Up()
+	assert.Eventually(t, func() bool {
+		i, err := cache.Get(ctx, k)
+		return err == nil && string(i.Value) == k
+	}, 2*time.Second, 50*time.Millisecond)
+}
+
+func TestEjectAllServers(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:        []string{srv.Addr()},
+		MaxFailures:  1,
+		EjectTimeout: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	srv.Down()
+	_, err = cache.Get(ctx, randSeq(16))
+	assert.Error(t, err)
+	srv.Up()
+	// the only server is tried even though it's ejected.
+	if _, err := cache.Get(ctx, randSeq(16)); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrCacheMiss, err)
+	}
+}
+
+func TestEjectCallerTimeouts(t *testing.T) {
+	for name, mux := range map[string]int{"Pool": 0, "Multiplex": 1} {
+		t.Run(name, func(t *testing.T) {
+			cluster := mctest.NewCluster(2)
+			defer cluster.Close()
+
+			opts := &mc.Options{
+				Addrs:                 cluster.Addrs(),
+				MaxFailures:           1,
+				EjectTimeout:          time.Hour,
+				MultiplexConnsPerAddr: mux,
+			}
+			cache, err := mc.New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cache.Close()
+			var (
+				ctx = context.Background()
+				k   string
+			)
+			for k = randSeq(16); opts.PickServer(k)[0] != cluster[0].Addr(); k = randSeq(16) {
+			}
+			if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+				t.Fatal(err)
+			}
+
+			cluster[0].SetLatency(50 * time.Millisecond)
+			timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			_, err = cache.Get(timeout, k)
+			cancel()
+			assert.Error(t, err)
+			cluster[0].SetLatency(0)
+			// the deadline of the caller doesn't eject the server.
+			if i, err := cache.Get(ctx, k); assert.NoError(t, err) {
+				assert.Equal(t, k, string(i.Value))
+			}
+		})
+	}
+}
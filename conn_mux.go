@@ -0,0 +1,339 @@
+package mc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+var errMuxClosed = errors.New("memcache: multiplexed connection closed")
+
+// maxMuxBuffer is the largest response buffer kept in muxBuffers.
+const maxMuxBuffer = 64 << 10
+
+// muxBuffers holds the buffers the reader goroutines read responses into. A buffer
+// is put back once the value is appended to the dst of its request, or the response
+// is dropped. Values returned to the caller as is keep their buffer.
+var muxBuffers = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+func putMuxBuffer(buf *[]byte) {
+	if cap(*buf) <= maxMuxBuffer {
+		muxBuffers.Put(buf)
+	}
+}
+
+// muxConn is a connection shared by concurrent requests: each request is tagged
+// with a unique Opaque, which the server copies into the response, and a reader
+// goroutine hands the responses over to the waiting requests.
+type muxConn struct {
+	nc          net.Conn
+	ap          *addrPool
+	connectedAt time.Time
+
+	// wmu serializes writes of packet, the header and the value are written
+	// in a single writev.
+	wmu    sync.Mutex
+	packet protocol.Packet
+	vec    [2][]byte
+	bufs   net.Buffers
+
+	mu      sync.Mutex
+	opaque  uint32
+	pending map[uint32]chan muxResponse
+	closed  bool
+	// retired connections take no new requests and are closed once the pending
+	// ones are answered.
+	retired bool
+}
+
+type muxResponse struct {
+	data, extras []byte
+	cas          uint64
+	// buf holds data and extras, nil if there is none.
+	buf *[]byte
+	// received bytes, for Observer.
+	received int
+	err      error
+}
+
+func (p *pool) dialMux(ap *addrPool, addr string) (*muxConn, error) {
+	nc, err := net.DialTimeout("tcp", addr, p.dialTimeout)
+	ap.dials.Add(1)
+	if err != nil {
+		p.health.report(addr, &ap.node, err)
+		ap.dialFailures.Add(1)
+		return nil, err
+	}
+	ap.numOpen.Add(1)
+	m := &muxConn{
+		nc:          nc,
+		ap:          ap,
+		connectedAt: time.Now(),
+		pending:     make(map[uint32]chan muxResponse),
+	}
+	go m.readLoop()
+	return m, nil
+}
+
+// getMux returns a multiplexed connection to addr, connections are handed out
+// round-robin and (re)dialed on demand. The connection is nil if addr isn't one
+// of the servers, such requests use a connection of their own.
+func (p *pool) getMux(addr string) (*muxConn, error) {
+	if p.closed.Load() {
+		return nil, ErrClientClosed
+	}
+	ap, ok := p.servers.Load().addrs[addr]
+	if !ok {
+		return nil, nil
+	}
+	i := ap.muxNext.Add(1) % uint32(len(ap.mux))
+
+	ap.muxMu.Lock()
+	defer ap.muxMu.Unlock()
+	switch {
+	case p.closed.Load():
+		return nil, ErrClientClosed
+	case ap.node.removed.Load():
+		return nil, nil
+	}
+	m := ap.mux[i]
+	if m != nil && time.Since(m.connectedAt) >= p.connMaxLifetime {
+		ap.maxLifetimeClosed.Add(1)
+		m.retire()
+		m = nil
+	}
+	if m == nil || m.broken() {
+		var err error
+		if m, err = p.dialMux(ap, addr); err != nil {
+			return nil, err
+		}
+		ap.mux[i] = m
+	}
+	return m, nil
+}
+
+// drainMux retires the multiplexed connections of a closed pool or removed server.
+func (ap *addrPool) drainMux() {
+	ap.muxMu.Lock()
+	defer ap.muxMu.Unlock()
+	for i, m := range ap.mux {
+		if m != nil {
+			m.retire()
+			ap.mux[i] = nil
+		}
+	}
+}
+
+func (m *muxConn) roundTrip(ctx context.Context, opcode protocol.Opcode, key, data, extras []byte, cas uint64) muxResponse {
+	ch := make(chan muxResponse, 1)
+	m.mu.Lock()
+	if m.closed || m.retired {
+		m.mu.Unlock()
+		return muxResponse{err: errMuxClosed}
+	}
+	m.opaque++
+	opaque := m.opaque
+	m.pending[opaque] = ch
+	m.mu.Unlock()
+
+	if err := m.write(ctx, opcode, opaque, key, data, extras, cas); err != nil {
+		// fails the pending requests including this one.
+		m.fail(checkError(err))
+	}
+	select {
+	case r := <-ch:
+		return r
+	case <-ctx.Done():
+		m.mu.Lock()
+		delete(m.pending, opaque)
+		m.closeIfRetired()
+		m.mu.Unlock()
+		return muxResponse{err: ctx.Err()}
+	}
+}
+
+func (m *muxConn) write(ctx context.Context, opcode protocol.Opcode, opaque uint32, key, data, extras []byte, cas uint64) error {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+	if deadline, ok := ctx.Deadline(); ok {
+		m.nc.SetWriteDeadline(deadline)
+		defer m.nc.SetWriteDeadline(time.Time{})
+	}
+	m.packet.Reset()
+	{
+		m.packet.CAS = cas
+		m.packet.Key = key
+		m.packet.Data = data
+		m.packet.Extras = extras
+		m.packet.Opcode = opcode
+		m.packet.Opaque = opaque
+	}
+	m.vec = [2][]byte{m.packet.Header(len(data)), data}
+	m.bufs = m.vec[:]
+	_, err := m.bufs.WriteTo(m.nc)
+	m.vec = [2][]byte{}
+	return err
+}
+
+func (m *muxConn) readLoop() {
+	var (
+		packet protocol.Packet
+		r      = bufio.NewReader(m.nc)
+	)
+	for {
+		packet.Reset()
+		buf := muxBuffers.Get().(*[]byte)
+		err := packet.ReadInto(r, (*buf)[:0])
+		if _, ok := err.(protocol.Status); !ok && err != nil {
+			m.fail(checkError(err))
+			return
+		}
+		var r muxResponse
+		if err != nil {
+			putMuxBuffer(buf)
+			r = muxResponse{received: 24, err: checkError(err)}
+		} else {
+			// extras are moved past the value, as they are overwritten by the next read.
+			n := len(packet.Data)
+			*buf = append(packet.Data, packet.Extras...)
+			r = muxResponse{
+				data:     (*buf)[:n:n],
+				extras:   (*buf)[n:],
+				cas:      packet.CAS,
+				buf:      buf,
+				received: 24 + len(packet.Extras) + len(packet.Key) + n,
+			}
+		}
+		m.mu.Lock()
+		ch, ok := m.pending[packet.Opaque]
+		delete(m.pending, packet.Opaque)
+		m.closeIfRetired()
+		m.mu.Unlock()
+		// requests that gave up waiting are no longer pending.
+		switch {
+		case ok:
+			ch <- r
+		case r.buf != nil:
+			putMuxBuffer(r.buf)
+		}
+	}
+}
+
+// fail closes the connection and fails the pending requests with err.
+func (m *muxConn) fail(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for opaque, ch := range m.pending {
+		ch <- muxResponse{err: err}
+		delete(m.pending, opaque)
+	}
+	if !m.closed {
+		m.ap.errorClosed.Add(1)
+		m.close()
+	}
+}
+
+func (m *muxConn) broken() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+func (m *muxConn) retire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retired = true
+	m.closeIfRetired()
+}
+
+// closeIfRetired must be called with mu held.
+func (m *muxConn) closeIfRetired() {
+	if m.retired && !m.closed && len(m.pending) == 0 {
+		m.close()
+	}
+}
+
+// close must be called with mu held.
+func (m *muxConn) close() {
+	m.closed = true
+	m.nc.Close()
+	m.ap.numOpen.Add(-1)
+}
+
+// muxExchange sends the request over a multiplexed connection to the first
+// available server of addrs, the value is appended to dst unless it's nil.
+func (c *Client) muxExchange(ctx context.Context, start time.Time, addrs []string, opcode protocol.Opcode, key string, data, extras []byte, cas uint64, dst []byte) (_ []byte, _ []byte, _ uint64, err error) {
+	if len(addrs) == 0 {
+		return nil, nil, 0, ErrNoServers
+	}
+	var (
+		m    *muxConn
+		addr string
+		r    muxResponse
+		hash = c.opts.KeyHashFunc(key)
+	)
+	for _, addr = range addrs {
+		// a connection retired in between is replaced on the next attempt.
+		for {
+			if m, err = c.pool.getMux(addr); err != nil || m == nil {
+				break
+			}
+			if r = m.roundTrip(ctx, opcode, hash, data, extras, cas); r.err != errMuxClosed {
+				break
+			}
+		}
+		if err == nil || err == ErrClientClosed || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		if c.opts.Observer != nil {
+			c.observe(opcode, addr, nil, 1, start, err)
+		}
+		return nil, nil, 0, opError(opcode, addr, key, err)
+	}
+	if m == nil {
+		conn, err := c.pool.getConn(ctx, addr)
+		if err != nil {
+			return nil, nil, 0, opError(opcode, addr, key, err)
+		}
+		return c.exchange(ctx, conn, start, opcode, key, data, extras, cas, true, dst)
+	}
+	if c.opts.Tracer != nil {
+		if span := spanFromContext(ctx); span != nil {
+			span.SetAttribute(AttrServer, addr)
+		}
+	}
+	if c.opts.Observer != nil {
+		c.opts.Observer.Observe(Event{
+			Opcode:   opcode,
+			Addr:     addr,
+			Keys:     1,
+			Sent:     24 + len(extras) + len(hash) + len(data),
+			Received: r.received,
+			Latency:  time.Since(start),
+			Err:      r.err,
+		})
+	}
+	c.pool.record(addr, opcode, r.err)
+	c.pool.health.report(addr, &m.ap.node, healthError(ctx, r.err))
+	switch {
+	case r.buf == nil:
+	case dst != nil:
+		r.data = append(dst, r.data...)
+		r.extras = append(r.data[len(r.data):], r.extras...)
+		putMuxBuffer(r.buf)
+	case len(r.data)+len(r.extras) == 0:
+		// nothing to keep, the empty slices must not share the buffer either.
+		r.data, r.extras = r.data[:0:0], r.extras[:0:0]
+		putMuxBuffer(r.buf)
+	}
+	return r.data, r.extras, r.cas, opError(opcode, addr, key, r.err)
+}
@@ -1,57 +1,316 @@
 package mc
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-func (c *Client) pickServer(key string) (conn *conn, err error) {
-	addrs := c.opts.PickServer(key)
+// pickServer connects to the first available server for the key, addr is the last
+// server tried.
+func (c *Client) pickServer(ctx context.Context, key string) (conn *conn, addr string, err error) {
+	return c.connect(ctx, c.pickAddrs(key))
+}
+
+// connect connects to the first available server of addrs, addr is the last server
+// tried.
+func (c *Client) connect(ctx context.Context, addrs []string) (conn *conn, addr string, err error) {
 	if len(addrs) == 0 {
-		return nil, ErrNoServers
+		return nil, "", ErrNoServers
 	}
-	for _, addr := range addrs {
-		if conn, err = c.pool.getConn(addr); err == nil {
+	for _, addr = range addrs {
+		if conn, err = c.pool.getConn(ctx, addr); err == nil || err == ErrClientClosed || ctx.Err() != nil {
 			return
 		}
 	}
 	return
 }
 
+// pickAddrs returns the servers for the key in PickServer order, skipping ejected ones.
+func (c *Client) pickAddrs(key string) []string {
+	s := c.pool.servers.Load()
+	return c.pool.health.filter(s, s.pick(key))
+}
+
 type pool struct {
-	idle            map[string]chan *conn
+	servers atomic.Pointer[servers]
+	// mu serializes SetServers.
+	mu              sync.Mutex
 	dialTimeout     time.Duration
 	connMaxLifetime time.Duration
+	connMaxIdleTime time.Duration
+	health          *health
+	closed          atomic.Bool
+	done            chan struct{}
 }
 
-func (p *pool) getConn(addr string) (conn *conn, err error) {
-	select {
-	case conn := <-p.idle[addr]:
-		return conn, nil
-	default:
+// servers is the set of servers along with the PickServer built for them, it's
+// replaced as a whole by SetServers.
+type servers struct {
+	pick  func(key string) []string
+	addrs map[string]*addrPool
+}
+
+// addrPool holds connections to a single server. open is a semaphore of open
+// connections when MaxOpenConnsPerAddr is set: getConn waits for either an idle
+// connection or a free slot.
+type addrPool struct {
+	idle    chan *conn
+	open    chan struct{}
+	numOpen atomic.Int64
+	node    node
+
+	// mux connections, if MultiplexConnsPerAddr is set.
+	muxMu   sync.Mutex
+	mux     []*muxConn
+	muxNext atomic.Uint32
+
+	waitCount         atomic.Int64
+	waitDuration      atomic.Int64
+	maxIdleClosed     atomic.Int64
+	maxIdleTimeClosed atomic.Int64
+	maxLifetimeClosed atomic.Int64
+
+	dials        atomic.Int64
+	dialFailures atomic.Int64
+	errorClosed  atomic.Int64
+	ops          [256]opCounters
+}
+
+func newAddrPool(o *Options) *addrPool {
+	p := &addrPool{
+		idle: make(chan *conn, o.MaxIdleConnsPerAddr),
+	}
+	if o.MaxOpenConnsPerAddr > 0 {
+		p.open = make(chan struct{}, o.MaxOpenConnsPerAddr)
+	}
+	if o.MultiplexConnsPerAddr > 0 {
+		p.mux = make([]*muxConn, o.MultiplexConnsPerAddr)
+	}
+	return p
+}
+
+func (p *pool) getConn(ctx context.Context, addr string) (conn *conn, err error) {
+	if p.closed.Load() {
+		return nil, ErrClientClosed
 	}
-	if conn, err = openConn(addr, p.dialTimeout); err != nil {
+	ap, ok := p.servers.Load().addrs[addr]
+	if !ok {
+		// not one of the servers (anymore), such connections aren't pooled.
+		return p.dial(nil, addr)
+	}
+	for {
+		select {
+		case conn := <-ap.idle:
+			if p.expired(ap, conn) {
+				continue
+			}
+			conn.sent, conn.received = 0, 0
+			return conn, nil
+		default:
+		}
+		if ap.open == nil {
+			return p.dial(ap, addr)
+		}
+		select {
+		case ap.open <- struct{}{}:
+			return p.dial(ap, addr)
+		default:
+		}
+
+		start := time.Now()
+		ap.waitCount.Add(1)
+		select {
+		case conn := <-ap.idle:
+			ap.waitDuration.Add(int64(time.Since(start)))
+			if p.expired(ap, conn) {
+				continue
+			}
+			conn.sent, conn.received = 0, 0
+			return conn, nil
+		case ap.open <- struct{}{}:
+			ap.waitDuration.Add(int64(time.Since(start)))
+			return p.dial(ap, addr)
+		case <-ctx.Done():
+			ap.waitDuration.Add(int64(time.Since(start)))
+			return nil, ctx.Err()
+		case <-p.done:
+			ap.waitDuration.Add(int64(time.Since(start)))
+			return nil, ErrClientClosed
+		}
+	}
+}
+
+// dial opens a connection, the open slot must be already taken.
+func (p *pool) dial(ap *addrPool, addr string) (*conn, error) {
+	conn, err := openConn(addr, p.dialTimeout)
+	if ap != nil {
+		ap.dials.Add(1)
+	}
+	if err != nil {
+		if ap != nil {
+			p.health.report(addr, &ap.node, err)
+			ap.dialFailures.Add(1)
+			if ap.open != nil {
+				<-ap.open
+			}
+		}
 		return nil, err
 	}
+	if ap != nil {
+		ap.numOpen.Add(1)
+		conn.pool = ap
+	}
 	return conn, nil
 }
 
-func (p *pool) condRelease(conn *conn, err error) {
+// expired closes the idle connection if it outlived ConnMaxLifetime or ConnMaxIdleTime.
+func (p *pool) expired(ap *addrPool, conn *conn) bool {
+	switch {
+	case time.Since(conn.connectedAt) >= p.connMaxLifetime:
+		ap.maxLifetimeClosed.Add(1)
+	case p.connMaxIdleTime > 0 && time.Since(conn.idleSince) >= p.connMaxIdleTime:
+		ap.maxIdleTimeClosed.Add(1)
+	default:
+		return false
+	}
+	p.closeConn(ap, conn)
+	return true
+}
+
+func (p *pool) closeConn(ap *addrPool, conn *conn) {
+	conn.close()
+	if ap == nil {
+		return
+	}
+	ap.numOpen.Add(-1)
+	if ap.open != nil {
+		<-ap.open
+	}
+}
+
+// condRelease returns the connection to the pool unless err may have left it broken,
+// errors caused by ctx don't count as failures of the server.
+func (p *pool) condRelease(ctx context.Context, conn *conn, err error) {
 	conn.packet.Reset()
+	ap := conn.pool
+	if ap != nil {
+		p.health.report(conn.addr, &ap.node, healthError(ctx, err))
+	}
 	if time.Since(conn.connectedAt) >= p.connMaxLifetime {
-		conn.close()
+		if ap != nil {
+			ap.maxLifetimeClosed.Add(1)
+		}
+		p.closeConn(ap, conn)
 		return
 	}
 
 	switch err {
-	case nil, ErrCacheMiss, ErrNotStored, ErrBadIncrDec, ErrCASConflict:
+	case nil, ErrCacheMiss, ErrAlreadyExists, ErrNotStored, ErrBadIncrDec, ErrCASConflict:
 	default:
-		conn.close()
+		if ap != nil {
+			ap.errorClosed.Add(1)
+		}
+		p.closeConn(ap, conn)
+		return
+	}
+	if ap == nil || p.closed.Load() || ap.node.removed.Load() {
+		p.closeConn(ap, conn)
 		return
 	}
 
+	conn.idleSince = time.Now()
 	select {
-	case p.idle[conn.addr] <- conn:
+	case ap.idle <- conn:
+		// lost the race with close or SetServers, which may have drained the pool already.
+		if p.closed.Load() || ap.node.removed.Load() {
+			p.drain(ap)
+		}
 	default:
-		conn.close()
+		ap.maxIdleClosed.Add(1)
+		p.closeConn(ap, conn)
+	}
+}
+
+// close makes the pool reject new requests, closes idle connections and stops
+// health probes. Connections in use are closed once released.
+func (p *pool) close() bool {
+	if !p.closed.CompareAndSwap(false, true) {
+		return false
+	}
+	close(p.done)
+	p.health.stop()
+	for _, ap := range p.servers.Load().addrs {
+		p.drain(ap)
+	}
+	return true
+}
+
+func (p *pool) drain(ap *addrPool) {
+	if ap.mux != nil {
+		ap.drainMux()
+	}
+	p.drainIdleConns(ap)
+}
+
+// drainIdle closes the idle connections to addr, e.g. after one of them broke: the
+// rest are likely broken too.
+func (p *pool) drainIdle(addr string) {
+	if ap, ok := p.servers.Load().addrs[addr]; ok {
+		p.drainIdleConns(ap)
+	}
+}
+
+func (p *pool) drainIdleConns(ap *addrPool) {
+	for {
+		select {
+		case conn := <-ap.idle:
+			p.closeConn(ap, conn)
+		default:
+			return
+		}
+	}
+}
+
+// PoolStats describes connections to a single server, similar to sql.DBStats.
+type PoolStats struct {
+	MaxOpenConnections int // 0 means unlimited.
+	OpenConnections    int
+	InUse              int
+	Idle               int
+
+	WaitCount         int64         // The total number of connections waited for.
+	WaitDuration      time.Duration // The total time blocked waiting for a connection.
+	MaxIdleClosed     int64         // The total number of connections closed due to MaxIdleConnsPerAddr.
+	MaxIdleTimeClosed int64         // The total number of connections closed due to ConnMaxIdleTime.
+	MaxLifetimeClosed int64         // The total number of connections closed due to ConnMaxLifetime.
+}
+
+// PoolStats returns connection pool statistics per server address.
+func (c *Client) PoolStats() map[string]PoolStats {
+	addrs := c.pool.servers.Load().addrs
+	stats := make(map[string]PoolStats, len(addrs))
+	for addr, ap := range addrs {
+		stats[addr] = ap.stats()
+	}
+	return stats
+}
+
+func (ap *addrPool) stats() PoolStats {
+	var (
+		open = int(ap.numOpen.Load())
+		idle = min(len(ap.idle), open)
+	)
+	return PoolStats{
+		MaxOpenConnections: cap(ap.open),
+		OpenConnections:    open,
+		InUse:              open - idle,
+		Idle:               idle,
+		WaitCount:          ap.waitCount.Load(),
+		WaitDuration:       time.Duration(ap.waitDuration.Load()),
+		MaxIdleClosed:      ap.maxIdleClosed.Load(),
+		MaxIdleTimeClosed:  ap.maxIdleTimeClosed.Load(),
+		MaxLifetimeClosed:  ap.maxLifetimeClosed.Load(),
 	}
 }
@@ -0,0 +1,223 @@
+package mc_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxOpenConnsPerAddr(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:               []string{srv.Addr()},
+		MaxOpenConnsPerAddr: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx = context.Background()
+		k   = randSeq(16)
+		wg  sync.WaitGroup
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+		t.Fatal(err)
+	}
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				if _, err := cache.Get(ctx, k); !assert.NoError(t, err) {
+					return
+				}
+				assert.LessOrEqual(t, cache.PoolStats()[srv.Addr()].OpenConnections, 2)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := cache.PoolStats()[srv.Addr()]
+	assert.Equal(t, 2, stats.MaxOpenConnections)
+	assert.LessOrEqual(t, stats.OpenConnections, 2)
+	assert.Equal(t, stats.OpenConnections, stats.Idle)
+	assert.NotZero(t, stats.WaitCount)
+}
+
+func TestMaxOpenConnsPerAddrDeadline(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:               []string{srv.Addr()},
+		MaxOpenConnsPerAddr: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetLatency(300 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Get(context.Background(), randSeq(16))
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := cache.Get(ctx, randSeq(16)); assert.Error(t, err) {
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	<-done
+
+	stats := cache.PoolStats()[srv.Addr()]
+	assert.Equal(t, int64(1), stats.WaitCount)
+	assert.GreaterOrEqual(t, stats.WaitDuration, 50*time.Millisecond)
+}
+
+func TestConnMaxIdleTime(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:           []string{srv.Addr()},
+		ConnMaxIdleTime: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for range 2 {
+		if _, err := cache.Get(ctx, randSeq(16)); assert.Error(t, err) {
+			assert.Equal(t, mc.ErrCacheMiss, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	stats := cache.PoolStats()[srv.Addr()]
+	assert.Equal(t, int64(1), stats.MaxIdleTimeClosed)
+	assert.Equal(t, 1, stats.OpenConnections)
+}
+
+func TestClose(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs: []string{srv.Addr()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx = context.Background()
+		k   = randSeq(16)
+		wg  sync.WaitGroup
+	)
+	if err := cache.Set(ctx, &mc.Item{Key: k, Value: []byte(k)}); err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, err := cache.Get(ctx, k); err != nil {
+					assert.Equal(t, mc.ErrClientClosed, err)
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, cache.Close())
+	wg.Wait()
+	assert.NoError(t, cache.Close())
+
+	stats := cache.PoolStats()[srv.Addr()]
+	assert.Zero(t, stats.OpenConnections)
+	assert.Zero(t, stats.Idle)
+
+	if _, err := cache.Get(ctx, k); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrClientClosed, err)
+	}
+	if _, err := cache.GetMulti(ctx, k); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrClientClosed, err)
+	}
+	if _, err := cache.DeleteMulti(ctx, k); assert.Error(t, err) {
+		assert.Equal(t, mc.ErrClientClosed, err)
+	}
+}
+
+func TestCloseWakesWaiters(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:               []string{srv.Addr()},
+		MaxOpenConnsPerAddr: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetLatency(300 * time.Millisecond)
+
+	go cache.Get(context.Background(), randSeq(16))
+	time.Sleep(50 * time.Millisecond)
+
+	errs := make(chan error)
+	go func() {
+		_, err := cache.Get(context.Background(), randSeq(16))
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cache.Close()
+	select {
+	case err := <-errs:
+		assert.Equal(t, mc.ErrClientClosed, err)
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("waiter wasn't woken up by Close")
+	}
+}
+
+func TestMaxOpenConnsPerAddrEnvelopes(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs:               []string{srv.Addr()},
+		MaxOpenConnsPerAddr: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	// envelopes are resolved with requests of their own, which need the only connection.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := cache.Set(ctx, &mc.Item{Key: "ns", Value: []byte("value")}, mc.WithNamespace("namespace")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(ctx, &mc.Item{Key: "scaled", Value: []byte("value")}, mc.WithExpiration(60, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if items, err := cache.GetMulti(ctx, "ns", "scaled"); assert.NoError(t, err) {
+		assert.Len(t, items, 2)
+	}
+	if items, err := cache.GetAndTouchMulti(ctx, 120, "ns", "scaled"); assert.NoError(t, err) {
+		assert.Len(t, items, 2)
+	}
+	var buf bytes.Buffer
+	if err := cache.GetTo(ctx, "ns", &buf); assert.NoError(t, err) {
+		assert.Equal(t, "value", buf.String())
+	}
+}
@@ -2,26 +2,131 @@ package mc
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/kinescope/mc/protocol"
 )
 
-func (c *Client) request(ctx context.Context, opcode protocol.Opcode, key string, data, extras []byte, cas uint64) (_ []byte, _ []byte, _ uint64, retErr error) {
+// request sends the request and reads the response, the value is appended to dst
+// unless it's nil.
+func (c *Client) request(ctx context.Context, opcode protocol.Opcode, key string, data, extras []byte, cas uint64, dst []byte) ([]byte, []byte, uint64, error) {
+	retry := c.opts.Retry
+	if retry == nil {
+		return c.roundTrip(ctx, opcode, key, data, extras, cas, dst)
+	}
+	for attempt := 1; ; attempt++ {
+		respData, respExtras, respCAS, err := c.roundTrip(ctx, opcode, key, data, extras, cas, dst)
+		if err == nil || attempt >= retry.MaxAttempts || !retry.retryable(opcode, cas, err) || !retry.backoff(ctx, attempt+1) {
+			return respData, respExtras, respCAS, err
+		}
+		var opErr *OpError
+		if errors.As(err, &opErr) && isServerFailure(opErr.Err) {
+			c.pool.drainIdle(opErr.Addr)
+		}
+	}
+}
+
+func (c *Client) roundTrip(ctx context.Context, opcode protocol.Opcode, key string, data, extras []byte, cas uint64, dst []byte) ([]byte, []byte, uint64, error) {
+	if err := c.checkRequest(ctx, key); err != nil {
+		return nil, nil, 0, err
+	}
+	var start time.Time
+	if c.opts.Observer != nil {
+		start = time.Now()
+	}
+	switch {
+	case c.opts.BatchWindow > 0 && opcode == protocol.Get:
+		return c.coalesce(ctx, start, key, dst)
+	case c.opts.MultiplexConnsPerAddr > 0:
+		return c.muxExchange(ctx, start, c.pickAddrs(key), opcode, key, data, extras, cas, dst)
+	}
+	return c.exchangeFirst(ctx, start, c.pickAddrs(key), opcode, key, data, extras, cas, dst)
+}
+
+// exchangeFirst sends the request to the first server of addrs that could be
+// connected to.
+func (c *Client) exchangeFirst(ctx context.Context, start time.Time, addrs []string, opcode protocol.Opcode, key string, data, extras []byte, cas uint64, dst []byte) ([]byte, []byte, uint64, error) {
+	conn, addr, err := c.connect(ctx, addrs)
+	if err != nil {
+		if c.opts.Observer != nil {
+			c.observe(opcode, addr, nil, 1, start, err)
+		}
+		if addr != "" {
+			err = opError(opcode, addr, key, err)
+		}
+		return nil, nil, 0, err
+	}
+	if c.opts.Tracer != nil {
+		if span := spanFromContext(ctx); span != nil {
+			span.SetAttribute(AttrServer, conn.addr)
+		}
+	}
+	return c.exchange(ctx, conn, start, opcode, key, data, extras, cas, false, dst)
+}
+
+// requestAddr sends the request to addr only. The request is aborted as soon as
+// ctx is done, so that it can be raced against another one.
+func (c *Client) requestAddr(ctx context.Context, addr string, opcode protocol.Opcode, key string, data, extras []byte, cas uint64) ([]byte, []byte, uint64, error) {
+	if err := c.checkRequest(ctx, key); err != nil {
+		return nil, nil, 0, err
+	}
+	var start time.Time
+	if c.opts.Observer != nil {
+		start = time.Now()
+	}
+	if c.opts.MultiplexConnsPerAddr > 0 {
+		return c.muxExchange(ctx, start, []string{addr}, opcode, key, data, extras, cas, nil)
+	}
+	conn, err := c.pool.getConn(ctx, addr)
+	if err != nil {
+		if c.opts.Observer != nil {
+			c.observe(opcode, addr, nil, 1, start, err)
+		}
+		return nil, nil, 0, opError(opcode, addr, key, err)
+	}
+	return c.exchange(ctx, conn, start, opcode, key, data, extras, cas, true, nil)
+}
+
+func (c *Client) checkRequest(ctx context.Context, key string) error {
 	select {
 	case <-ctx.Done():
-		return nil, nil, 0, ctx.Err()
+		return ctx.Err()
 	default:
 	}
+	if c.pool.closed.Load() {
+		return ErrClientClosed
+	}
 	if !checkKey(key) {
-		return nil, nil, 0, ErrMalformedKey
+		return ErrMalformedKey
 	}
-	conn, err := c.pickServer(key)
-	if err != nil {
-		return nil, nil, 0, err
+	return nil
+}
+
+// exchange sends the request over conn and reads the response, the connection is
+// released afterwards. The value is read into dst unless it's nil.
+func (c *Client) exchange(ctx context.Context, conn *conn, start time.Time, opcode protocol.Opcode, key string, data, extras []byte, cas uint64, abortable bool, dst []byte) (_ []byte, _ []byte, _ uint64, retErr error) {
+	var aborted func() bool
+	if abortable {
+		aborted = context.AfterFunc(ctx, func() {
+			conn.nc.SetDeadline(time.Unix(1, 0))
+		})
 	}
 	defer func() {
-		c.pool.condRelease(conn, retErr)
+		releaseErr := retErr
+		if aborted != nil && !aborted() {
+			// the deadline has been reset or is about to be, the connection can't be reused.
+			if retErr != nil {
+				retErr = ctx.Err()
+			}
+			releaseErr = ctx.Err()
+		}
+		if c.opts.Observer != nil {
+			c.observe(opcode, conn.addr, conn, 1, start, retErr)
+		}
+		c.pool.record(conn.addr, opcode, retErr)
+		c.pool.condRelease(ctx, conn, releaseErr)
+		retErr = opError(opcode, conn.addr, key, retErr)
 	}()
 	if deadline, ok := ctx.Deadline(); ok {
 		conn.nc.SetDeadline(deadline)
@@ -30,7 +135,15 @@ func (c *Client) request(ctx context.Context, opcode protocol.Opcode, key string
 	if err := conn.sendPacket(opcode, c.opts.KeyHashFunc(key), data, extras, cas); err != nil {
 		return nil, nil, 0, err
 	}
-	packet, err := conn.readPacket()
+	var (
+		packet *protocol.Packet
+		err    error
+	)
+	if dst != nil {
+		packet, err = conn.readPacketInto(dst)
+	} else {
+		packet, err = conn.readPacket()
+	}
 	if err != nil {
 		return nil, nil, 0, err
 	}
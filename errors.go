@@ -1,8 +1,11 @@
 package mc
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/kinescope/mc/protocol"
 )
@@ -18,8 +21,72 @@ var (
 	ErrAlreadyExists    = errors.New("memcache: item already exists")
 	ErrValueTooLarge    = errors.New("memcache: value too large")
 	ErrInvalidArguments = errors.New("memcache: invalid arguments")
+	ErrClientClosed     = errors.New("memcache: client is closed")
+	ErrEnvelopedValue   = errors.New("memcache: can't append or prepend to a value with namespace or scaling expiration")
+	ErrBusy             = errors.New("memcache: server is busy")
+	ErrTemporaryFailure = errors.New("memcache: temporary failure")
+	ErrOutOfMemory      = errors.New("memcache: out of memory")
+	ErrNotSupported     = errors.New("memcache: not supported")
+	ErrUnknownCommand   = errors.New("memcache: unknown command")
 )
 
+// OpError tells which server and key a request failed on. Cache misses and the other
+// expected outcomes (ErrAlreadyExists, ErrNotStored, ErrCASConflict, ErrBadIncrDec)
+// are returned as is, use errors.Is to check for the rest.
+type OpError struct {
+	Op   protocol.Opcode
+	Addr string
+	// Key is empty for the errors of a whole batch.
+	Key string
+	Err error
+}
+
+func (e *OpError) Error() string {
+	var b strings.Builder
+	b.WriteString("memcache: ")
+	b.WriteString(e.Op.String())
+	if e.Key != "" {
+		fmt.Fprintf(&b, " %q", e.Key)
+	}
+	if e.Addr != "" {
+		b.WriteString(" on ")
+		b.WriteString(e.Addr)
+	}
+	b.WriteString(": ")
+	b.WriteString(strings.TrimPrefix(e.Err.Error(), "memcache: "))
+	return b.String()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// opError wraps failures into *OpError.
+func opError(op protocol.Opcode, addr, key string, err error) error {
+	switch err {
+	case nil, ErrCacheMiss, ErrAlreadyExists, ErrNotStored, ErrCASConflict, ErrBadIncrDec, ErrClientClosed:
+		return err
+	}
+	return &OpError{
+		Op:   op,
+		Addr: addr,
+		Key:  key,
+		Err:  err,
+	}
+}
+
+// IsRetryable reports whether the request may succeed if sent again: the server was
+// busy or temporarily failed, or the connection to it broke.
+func IsRetryable(err error) bool {
+	switch {
+	case errors.Is(err, ErrBusy), errors.Is(err, ErrTemporaryFailure):
+		return true
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return isServerFailure(err)
+}
+
 func checkError(err error) error {
 	switch e := err.(type) {
 	case protocol.Status:
@@ -38,9 +105,73 @@ func checkError(err error) error {
 			return ErrBadIncrDec
 		case protocol.StatusValueTooLarge:
 			return ErrValueTooLarge
+		case protocol.StatusBusy:
+			return ErrBusy
+		case protocol.StatusTemporaryFailure:
+			return ErrTemporaryFailure
+		case protocol.StatusOutOfMemory:
+			return ErrOutOfMemory
+		case protocol.StatusNotSupported:
+			return ErrNotSupported
+		case protocol.StatusUnknownCommand:
+			return ErrUnknownCommand
 		default:
 			return fmt.Errorf("memcache: status=%d", e)
 		}
 	}
 	return err
 }
+
+// MultiError is returned by batch operations along with the partial result when some
+// of the servers or keys failed. Keys absent from both the result and the error are
+// cache misses.
+type MultiError struct {
+	// Servers lists the servers that failed as a whole.
+	Servers map[string]error
+	// Keys lists every failed key, including the keys of failed servers.
+	Keys map[string]error
+}
+
+func (e *MultiError) addServer(addr string, err error) {
+	if e.Servers == nil {
+		e.Servers = make(map[string]error)
+	}
+	e.Servers[addr] = err
+}
+
+func (e *MultiError) addKey(key string, err error) {
+	if e.Keys == nil {
+		e.Keys = make(map[string]error)
+	}
+	e.Keys[key] = err
+}
+
+func (e *MultiError) Error() string {
+	errs := e.Unwrap()
+	if len(errs) == 0 {
+		return "memcache: no errors"
+	}
+	return fmt.Sprintf("memcache: %d keys on %d servers failed, first error: %v", len(e.Keys), len(e.Servers), errs[0])
+}
+
+// Unwrap returns distinct errors of the servers and keys, so that errors.Is and
+// errors.As look through them.
+func (e *MultiError) Unwrap() []error {
+	var (
+		errs []error
+		seen = make(map[string]bool)
+	)
+	for _, m := range []map[string]error{e.Servers, e.Keys} {
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := m[name]; !seen[err.Error()] {
+				seen[err.Error()], errs = true, append(errs, err)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,88 @@
+package mc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/kinescope/mc"
+	"github.com/kinescope/mc/mctest"
+	"github.com/kinescope/mc/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpError(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+
+	cache, err := mc.New(&mc.Options{
+		Addrs: []string{srv.Addr()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	var (
+		ctx = context.Background()
+		k   = randSeq(16)
+	)
+	_, err = cache.Get(ctx, k)
+	assert.Equal(t, mc.ErrCacheMiss, err, "expected outcomes aren't wrapped")
+
+	for status, sentinel := range map[protocol.Status]error{
+		protocol.StatusBusy:             mc.ErrBusy,
+		protocol.StatusTemporaryFailure: mc.ErrTemporaryFailure,
+		protocol.StatusOutOfMemory:      mc.ErrOutOfMemory,
+		protocol.StatusNotSupported:     mc.ErrNotSupported,
+		protocol.StatusUnknownCommand:   mc.ErrUnknownCommand,
+		protocol.StatusInternalError:    mc.ErrServerError,
+	} {
+		srv.FailNext(status, 1)
+		err := cache.Set(ctx, &mc.Item{Key: k})
+		if assert.ErrorIs(t, err, sentinel) {
+			var opErr *mc.OpError
+			if assert.ErrorAs(t, err, &opErr) {
+				assert.Equal(t, protocol.Opcode(protocol.Set), opErr.Op)
+				assert.Equal(t, srv.Addr(), opErr.Addr)
+				assert.Equal(t, k, opErr.Key)
+			}
+			assert.Contains(t, err.Error(), srv.Addr())
+		}
+		assert.Equal(t, status == protocol.StatusBusy || status == protocol.StatusTemporaryFailure, mc.IsRetryable(err), status)
+	}
+
+	srv.FailNext(protocol.StatusBusy, 1)
+	_, err = cache.GetMulti(ctx, k)
+	if assert.ErrorIs(t, err, mc.ErrBusy) {
+		var multiErr *mc.MultiError
+		if assert.ErrorAs(t, err, &multiErr) {
+			assert.Contains(t, multiErr.Servers, srv.Addr())
+		}
+	}
+
+	srv.Close()
+	_, err = cache.Get(ctx, k)
+	var opErr *mc.OpError
+	if assert.ErrorAs(t, err, &opErr) {
+		assert.Equal(t, srv.Addr(), opErr.Addr)
+	}
+	assert.True(t, mc.IsRetryable(err))
+}
+
+func TestIsRetryable(t *testing.T) {
+	for err, retryable := range map[error]bool{
+		mc.ErrBusy:               true,
+		mc.ErrTemporaryFailure:   true,
+		io.EOF:                   true,
+		io.ErrUnexpectedEOF:      true,
+		mc.ErrCacheMiss:          false,
+		mc.ErrServerError:        false,
+		context.Canceled:         false,
+		context.DeadlineExceeded: false,
+		errors.New("other"):      false,
+	} {
+		assert.Equal(t, retryable, mc.IsRetryable(err), err)
+	}
+}
@@ -1,20 +1,62 @@
 package clock
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
-var now = time.Now().Truncate(time.Second).Unix()
+var (
+	now     atomic.Int64
+	running atomic.Bool
 
-func init() {
-	go func() {
-		for tick := time.Tick(time.Second); ; {
-			atomic.StoreInt64(&now, (<-tick).Unix())
+	mu   sync.Mutex
+	refs int
+	stop chan struct{}
+)
+
+// Start starts updating the cached time every second, each Start must be
+// paired with Stop. Unix falls back to time.Now while the clock isn't running.
+func Start() {
+	mu.Lock()
+	defer mu.Unlock()
+	if refs++; refs != 1 {
+		return
+	}
+	now.Store(time.Now().Truncate(time.Second).Unix())
+	running.Store(true)
+	stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case tick := <-ticker.C:
+				now.Store(tick.Unix())
+			case <-stop:
+				return
+			}
 		}
-	}()
+	}(stop)
+}
+
+// Stop stops the clock once every Start has been paired.
+func Stop() {
+	mu.Lock()
+	defer mu.Unlock()
+	if refs == 0 {
+		return
+	}
+	if refs--; refs != 0 {
+		return
+	}
+	running.Store(false)
+	close(stop)
 }
 
 func Unix() int64 {
-	return atomic.LoadInt64(&now)
+	if running.Load() {
+		return now.Load()
+	}
+	return time.Now().Unix()
 }
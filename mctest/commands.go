@@ -0,0 +1,349 @@
+package mctest
+
+import (
+	"bufio"
+	"strconv"
+	"time"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+const (
+	version = "1.6.0-mctest"
+
+	maxKeyLen   = 250
+	maxValueLen = 1 << 20
+
+	// https://github.com/memcached/memcached/wiki/Programming#expiration
+	maxRelativeExpiration = 60 * 60 * 24 * 30
+)
+
+type item struct {
+	value []byte
+	flags uint32
+	cas   uint64
+	exp   time.Time
+}
+
+func (i *item) expired(now time.Time) bool {
+	return !i.exp.IsZero() && !now.Before(i.exp)
+}
+
+type command struct {
+	exec func(s *Server, req *request) response
+	// key is returned in the response.
+	withKey bool
+	// quiet commands don't respond on success (or on a miss for gets).
+	quiet bool
+}
+
+var commands = map[protocol.Opcode]command{
+	protocol.Get:       {exec: (*Server).get},
+	protocol.GetKQ:     {exec: (*Server).get, withKey: true, quiet: true},
+	protocol.Set:       {exec: (*Server).set},
+	protocol.Add:       {exec: (*Server).add},
+	protocol.SetQ:      {exec: (*Server).set, quiet: true},
+	protocol.AddQ:      {exec: (*Server).add, quiet: true},
+	protocol.DeleteQ:   {exec: (*Server).delete, quiet: true},
+	protocol.Replace:   {exec: (*Server).replace},
+	protocol.Append:    {exec: (*Server).append},
+	protocol.Prepend:   {exec: (*Server).prepend},
+	protocol.Delete:    {exec: (*Server).delete},
+	protocol.Increment: {exec: (*Server).incr},
+	protocol.Decrement: {exec: (*Server).decr},
+	protocol.Touch:     {exec: (*Server).touch},
+	protocol.GAT:       {exec: (*Server).gat},
+	protocol.GATQ:      {exec: (*Server).gat, quiet: true},
+	protocol.GATKQ:     {exec: (*Server).gat, withKey: true, quiet: true},
+	protocol.Noop:      {exec: (*Server).noop},
+	protocol.Version:   {exec: (*Server).version},
+}
+
+func (s *Server) exec(w *bufio.Writer, req *request) error {
+	cmd, ok := commands[req.opcode]
+	if !ok {
+		resp := errResponse(protocol.StatusUnknownCommand)
+		return resp.write(w, req)
+	}
+	if status, ok := s.injectedFailure(req.opcode); ok {
+		resp := errResponse(status)
+		return resp.write(w, req)
+	}
+	resp := cmd.exec(s, req)
+	if cmd.quiet {
+		switch {
+		case resp.status == protocol.StatusOK && !isGet(req.opcode):
+			return nil
+		case resp.status == protocol.StatusKeyNotFound && isGet(req.opcode):
+			return nil
+		}
+	}
+	if cmd.withKey && resp.status == protocol.StatusOK {
+		resp.key = req.key
+	}
+	return resp.write(w, req)
+}
+
+func isGet(opcode protocol.Opcode) bool {
+	switch opcode {
+	case protocol.Get, protocol.GetKQ, protocol.GAT, protocol.GATQ, protocol.GATKQ:
+		return true
+	}
+	return false
+}
+
+var statusText = map[protocol.Status]string{
+	protocol.StatusKeyNotFound:               "Not found",
+	protocol.StatusKeyExists:                 "Data exists for key.",
+	protocol.StatusValueTooLarge:             "Too large.",
+	protocol.StatusInvalidArguments:          "Invalid arguments",
+	protocol.StatusItemNotStored:             "Not stored.",
+	protocol.StatusIncrDecrOnNonNumericValue: "Non-numeric server-side value for incr or decr",
+	protocol.StatusUnknownCommand:            "Unknown command",
+	protocol.StatusOutOfMemory:               "Out of memory",
+	protocol.StatusNotSupported:              "Not supported",
+	protocol.StatusInternalError:             "Internal error",
+	protocol.StatusBusy:                      "Busy",
+	protocol.StatusTemporaryFailure:          "Temporary failure",
+}
+
+func errResponse(status protocol.Status) response {
+	return response{
+		status: status,
+		value:  []byte(statusText[status]),
+	}
+}
+
+// lookup returns a live item, evicting it if it has expired. Must be called with s.mu held.
+func (s *Server) lookup(key []byte) *item {
+	i, ok := s.items[string(key)]
+	if !ok {
+		return nil
+	}
+	if i.expired(time.Now()) {
+		delete(s.items, string(key))
+		return nil
+	}
+	return i
+}
+
+// store saves a copy of value under key. Must be called with s.mu held.
+func (s *Server) store(key, value []byte, flags, exp uint32) *item {
+	s.cas++
+	i := &item{
+		value: append([]byte(nil), value...),
+		flags: flags,
+		cas:   s.cas,
+		exp:   expiry(exp),
+	}
+	s.items[string(key)] = i
+	return i
+}
+
+func expiry(exp uint32) time.Time {
+	switch {
+	case exp == 0:
+		return time.Time{}
+	case exp > maxRelativeExpiration:
+		return time.Unix(int64(exp), 0)
+	}
+	return time.Now().Add(time.Duration(exp) * time.Second)
+}
+
+func checkKey(key []byte) bool {
+	return len(key) != 0 && len(key) <= maxKeyLen
+}
+
+func (s *Server) get(req *request) response {
+	if !checkKey(req.key) || len(req.extras) != 0 || len(req.value) != 0 {
+		return errResponse(protocol.StatusInvalidArguments)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return hit(s.lookup(req.key))
+}
+
+func (s *Server) touch(req *request) response {
+	if !checkKey(req.key) || len(req.extras) != 4 || len(req.value) != 0 {
+		return errResponse(protocol.StatusInvalidArguments)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.lookup(req.key)
+	if i == nil {
+		return errResponse(protocol.StatusKeyNotFound)
+	}
+	i.exp = expiry(endian.Uint32(req.extras))
+	return response{cas: i.cas}
+}
+
+func (s *Server) gat(req *request) response {
+	if !checkKey(req.key) || len(req.extras) != 4 || len(req.value) != 0 {
+		return errResponse(protocol.StatusInvalidArguments)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.lookup(req.key)
+	if i != nil {
+		i.exp = expiry(endian.Uint32(req.extras))
+	}
+	return hit(i)
+}
+
+func hit(i *item) response {
+	if i == nil {
+		return errResponse(protocol.StatusKeyNotFound)
+	}
+	extras := make([]byte, 4)
+	endian.PutUint32(extras, i.flags)
+	return response{
+		cas:    i.cas,
+		extras: extras,
+		value:  i.value,
+	}
+}
+
+const (
+	modeSet = iota
+	modeAdd
+	modeReplace
+)
+
+func (s *Server) set(req *request) response     { return s.update(req, modeSet) }
+func (s *Server) add(req *request) response     { return s.update(req, modeAdd) }
+func (s *Server) replace(req *request) response { return s.update(req, modeReplace) }
+
+func (s *Server) update(req *request, mode int) response {
+	if !checkKey(req.key) || len(req.extras) != 8 {
+		return errResponse(protocol.StatusInvalidArguments)
+	}
+	if len(req.value) > maxValueLen {
+		return errResponse(protocol.StatusValueTooLarge)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.lookup(req.key)
+	switch {
+	case mode == modeAdd && i != nil:
+		return errResponse(protocol.StatusKeyExists)
+	case mode == modeReplace && i == nil:
+		return errResponse(protocol.StatusKeyNotFound)
+	case req.cas != 0 && i == nil:
+		return errResponse(protocol.StatusKeyNotFound)
+	case req.cas != 0 && i.cas != req.cas:
+		return errResponse(protocol.StatusKeyExists)
+	}
+	i = s.store(req.key, req.value, endian.Uint32(req.extras[0:4]), endian.Uint32(req.extras[4:8]))
+	return response{cas: i.cas}
+}
+
+func (s *Server) append(req *request) response  { return s.concat(req, false) }
+func (s *Server) prepend(req *request) response { return s.concat(req, true) }
+
+// concat keeps flags and expiration of the existing item.
+func (s *Server) concat(req *request, prepend bool) response {
+	if !checkKey(req.key) || len(req.extras) != 0 {
+		return errResponse(protocol.StatusInvalidArguments)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.lookup(req.key)
+	switch {
+	case i == nil:
+		return errResponse(protocol.StatusItemNotStored)
+	case req.cas != 0 && i.cas != req.cas:
+		return errResponse(protocol.StatusKeyExists)
+	case len(i.value)+len(req.value) > maxValueLen:
+		return errResponse(protocol.StatusValueTooLarge)
+	}
+	head, tail := i.value, req.value
+	if prepend {
+		head, tail = tail, head
+	}
+	value := make([]byte, 0, len(head)+len(tail))
+	s.cas++
+	i.value, i.cas = append(append(value, head...), tail...), s.cas
+	return response{cas: i.cas}
+}
+
+func (s *Server) delete(req *request) response {
+	if !checkKey(req.key) || len(req.extras) != 0 || len(req.value) != 0 {
+		return errResponse(protocol.StatusInvalidArguments)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.lookup(req.key)
+	switch {
+	case i == nil:
+		return errResponse(protocol.StatusKeyNotFound)
+	case req.cas != 0 && i.cas != req.cas:
+		return errResponse(protocol.StatusKeyExists)
+	}
+	delete(s.items, string(req.key))
+	return response{}
+}
+
+func (s *Server) incr(req *request) response {
+	return s.incrDecr(req, func(v, delta uint64) uint64 { return v + delta })
+}
+
+func (s *Server) decr(req *request) response {
+	return s.incrDecr(req, func(v, delta uint64) uint64 {
+		if delta > v {
+			return 0
+		}
+		return v - delta
+	})
+}
+
+/*
+Extras:
+
+	Delta        (0-7)  : amount to add / subtract
+	Initial      (8-15) : value stored when the item is missing
+	Expiration   (16-19): 0xffffffff means "fail on a miss"
+*/
+func (s *Server) incrDecr(req *request, apply func(v, delta uint64) uint64) response {
+	if !checkKey(req.key) || len(req.extras) != 20 || len(req.value) != 0 {
+		return errResponse(protocol.StatusInvalidArguments)
+	}
+	var (
+		delta   = endian.Uint64(req.extras[0:8])
+		initial = endian.Uint64(req.extras[8:16])
+		exp     = endian.Uint32(req.extras[16:20])
+		result  uint64
+	)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch i := s.lookup(req.key); {
+	case i == nil && exp == 0xffffffff:
+		return errResponse(protocol.StatusKeyNotFound)
+	case i == nil:
+		result = initial
+		s.store(req.key, strconv.AppendUint(nil, result, 10), 0, exp)
+	case req.cas != 0 && i.cas != req.cas:
+		return errResponse(protocol.StatusKeyExists)
+	default:
+		v, err := strconv.ParseUint(string(i.value), 10, 64)
+		if err != nil {
+			return errResponse(protocol.StatusIncrDecrOnNonNumericValue)
+		}
+		result = apply(v, delta)
+		s.cas++
+		i.value, i.cas = strconv.AppendUint(nil, result, 10), s.cas
+	}
+	value := make([]byte, 8)
+	endian.PutUint64(value, result)
+	return response{
+		cas:   s.cas,
+		value: value,
+	}
+}
+
+func (s *Server) noop(*request) response {
+	return response{}
+}
+
+func (s *Server) version(*request) response {
+	return response{value: []byte(version)}
+}
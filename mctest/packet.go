@@ -0,0 +1,90 @@
+package mctest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+// requests larger than this are considered garbage and the connection is dropped.
+const maxBodyLen = 32 << 20
+
+var endian = binary.BigEndian
+
+type request struct {
+	opcode protocol.Opcode
+	opaque uint32
+	cas    uint64
+	extras []byte
+	key    []byte
+	value  []byte
+	header [24]byte
+	body   []byte
+}
+
+func (r *request) read(rd io.Reader) error {
+	if _, err := io.ReadFull(rd, r.header[:]); err != nil {
+		return err
+	}
+	if r.header[0] != protocol.MagicReq {
+		return fmt.Errorf("mctest: bad magic number in request")
+	}
+	var (
+		keyLen   = int(endian.Uint16(r.header[2:4]))
+		extraLen = int(r.header[4])
+		totalLen = int(endian.Uint32(r.header[8:12]))
+	)
+	if totalLen > maxBodyLen || keyLen+extraLen > totalLen {
+		return fmt.Errorf("mctest: malformed request")
+	}
+	if cap(r.body) < totalLen {
+		r.body = make([]byte, totalLen)
+	}
+	r.body = r.body[:totalLen]
+	if _, err := io.ReadFull(rd, r.body); err != nil {
+		return err
+	}
+	r.opcode = protocol.Opcode(r.header[1])
+	r.opaque = endian.Uint32(r.header[12:16])
+	r.cas = endian.Uint64(r.header[16:24])
+	{
+		r.extras = r.body[:extraLen]
+		r.key = r.body[extraLen : extraLen+keyLen]
+		r.value = r.body[extraLen+keyLen:]
+	}
+	return nil
+}
+
+type response struct {
+	status protocol.Status
+	cas    uint64
+	extras []byte
+	key    []byte
+	value  []byte
+}
+
+func (r *response) write(w *bufio.Writer, req *request) error {
+	var header [24]byte
+	{
+		header[0] = protocol.MagicResp
+		header[1] = byte(req.opcode)
+	}
+	endian.PutUint16(header[2:4], uint16(len(r.key)))
+	{
+		header[4] = byte(len(r.extras))
+		header[5] = protocol.DataTypeRawBytes
+	}
+	endian.PutUint16(header[6:8], uint16(r.status))
+	endian.PutUint32(header[8:12], uint32(len(r.extras)+len(r.key)+len(r.value)))
+	endian.PutUint32(header[12:16], req.opaque)
+	endian.PutUint64(header[16:24], r.cas)
+	for _, b := range [][]byte{header[:], r.extras, r.key, r.value} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
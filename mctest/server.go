@@ -0,0 +1,220 @@
+// Package mctest provides an in-process memcached server speaking the binary
+// protocol, so that code using the client can be tested without a real
+// memcached instance.
+package mctest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kinescope/mc/protocol"
+)
+
+// Server is a memcached server listening on a system-chosen port on the local
+// loopback interface.
+type Server struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	cas     uint64
+	items   map[string]*item
+	conns   map[net.Conn]struct{}
+	down    bool
+	closed  bool
+	wg      sync.WaitGroup
+	latency atomic.Int64
+	// failNext requests are answered with failStatus.
+	failNext   int
+	failStatus protocol.Status
+}
+
+// NewServer starts and returns a new Server. The caller should call Close when
+// finished, to shut it down.
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		if ln, err = net.Listen("tcp6", "[::1]:0"); err != nil {
+			panic(fmt.Sprintf("mctest: failed to listen on a port: %v", err))
+		}
+	}
+	s := &Server{
+		ln:    ln,
+		items: make(map[string]*item),
+		conns: make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Addr returns the address the server is listening on, in a form suitable
+// for mc.Options.Addrs.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close shuts down the server, closing all open connections, and blocks
+// until all connection goroutines have exited.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.ln.Close()
+	for nc := range s.conns {
+		nc.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Down simulates an outage: open connections are dropped and new ones are closed
+// right after accept, until Up is called. Items are kept.
+func (s *Server) Down() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = true
+	for nc := range s.conns {
+		nc.Close()
+	}
+}
+
+// Up brings the server back after Down.
+func (s *Server) Up() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = false
+}
+
+// SetLatency delays every response by d, e.g. to simulate a slow node.
+func (s *Server) SetLatency(d time.Duration) {
+	s.latency.Store(int64(d))
+}
+
+// FailNext makes the server answer the next n requests with status instead of
+// executing them, e.g. protocol.StatusBusy. Noop and Version are not affected.
+func (s *Server) FailNext(status protocol.Status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext, s.failStatus = n, status
+}
+
+func (s *Server) injectedFailure(opcode protocol.Opcode) (protocol.Status, bool) {
+	if opcode == protocol.Noop || opcode == protocol.Version {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failNext == 0 {
+		return 0, false
+	}
+	s.failNext--
+	return s.failStatus, true
+}
+
+// NumConns returns the number of open client connections.
+func (s *Server) NumConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Flush removes all items from the server.
+func (s *Server) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = make(map[string]*item)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		if !s.track(nc) {
+			nc.Close()
+			continue
+		}
+		s.wg.Add(1)
+		go s.serveConn(nc)
+	}
+}
+
+func (s *Server) track(nc net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.down {
+		return false
+	}
+	s.conns[nc] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(nc net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, nc)
+}
+
+func (s *Server) serveConn(nc net.Conn) {
+	defer s.wg.Done()
+	defer s.untrack(nc)
+	defer nc.Close()
+	var (
+		req request
+		r   = bufio.NewReader(nc)
+		w   = bufio.NewWriter(nc)
+	)
+	for {
+		if err := req.read(r); err != nil {
+			return
+		}
+		if d := time.Duration(s.latency.Load()); d > 0 {
+			time.Sleep(d)
+		}
+		if err := s.exec(w, &req); err != nil {
+			return
+		}
+		// pipelined requests are answered in one write.
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Cluster is a set of servers, e.g. to exercise key distribution.
+type Cluster []*Server
+
+// NewCluster starts n servers.
+func NewCluster(n int) Cluster {
+	c := make(Cluster, 0, n)
+	for range n {
+		c = append(c, NewServer())
+	}
+	return c
+}
+
+// Addrs returns the addresses of all servers in the cluster.
+func (c Cluster) Addrs() []string {
+	addrs := make([]string, 0, len(c))
+	for _, s := range c {
+		addrs = append(addrs, s.Addr())
+	}
+	return addrs
+}
+
+// Close shuts down all servers in the cluster.
+func (c Cluster) Close() {
+	for _, s := range c {
+		s.Close()
+	}
+}
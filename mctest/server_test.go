@@ -0,0 +1,273 @@
+package mctest_test
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kinescope/mc/mctest"
+	"github.com/kinescope/mc/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func dial(t *testing.T, srv *mctest.Server) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func roundTrip(conn net.Conn, p *protocol.Packet) error {
+	if err := p.Write(conn); err != nil {
+		return err
+	}
+	p.Reset()
+	return p.Read(conn)
+}
+
+func setExtras(flags, exp uint32) []byte {
+	extras := make([]byte, 8)
+	binary.BigEndian.PutUint32(extras[0:4], flags)
+	binary.BigEndian.PutUint32(extras[4:8], exp)
+	return extras
+}
+
+func TestServerSetGetCAS(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+
+	set := protocol.Packet{Opcode: protocol.Set, Key: []byte("k"), Data: []byte("v1"), Extras: setExtras(7, 0)}
+	if err := roundTrip(conn, &set); assert.NoError(t, err) {
+		cas := set.CAS
+		get := protocol.Packet{Opcode: protocol.Get, Key: []byte("k")}
+		if err := roundTrip(conn, &get); assert.NoError(t, err) {
+			assert.Equal(t, "v1", string(get.Data))
+			assert.Equal(t, uint32(7), binary.BigEndian.Uint32(get.Extras))
+			assert.Equal(t, cas, get.CAS)
+		}
+		stale := protocol.Packet{Opcode: protocol.Set, Key: []byte("k"), Data: []byte("v2"), Extras: setExtras(0, 0), CAS: cas + 1}
+		assert.Equal(t, protocol.Status(protocol.StatusKeyExists), roundTrip(conn, &stale))
+
+		add := protocol.Packet{Opcode: protocol.Add, Key: []byte("k"), Extras: setExtras(0, 0)}
+		assert.Equal(t, protocol.Status(protocol.StatusKeyExists), roundTrip(conn, &add))
+
+		del := protocol.Packet{Opcode: protocol.Delete, Key: []byte("k")}
+		if err := roundTrip(conn, &del); assert.NoError(t, err) {
+			get := protocol.Packet{Opcode: protocol.Get, Key: []byte("k")}
+			assert.Equal(t, protocol.Status(protocol.StatusKeyNotFound), roundTrip(conn, &get))
+		}
+	}
+}
+
+func TestServerIncrDecr(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+
+	extras := func(delta, initial uint64, exp uint32) []byte {
+		b := make([]byte, 20)
+		binary.BigEndian.PutUint64(b[0:8], delta)
+		binary.BigEndian.PutUint64(b[8:16], initial)
+		binary.BigEndian.PutUint32(b[16:20], exp)
+		return b
+	}
+	miss := protocol.Packet{Opcode: protocol.Increment, Key: []byte("n"), Extras: extras(1, 0, 0xffffffff)}
+	assert.Equal(t, protocol.Status(protocol.StatusKeyNotFound), roundTrip(conn, &miss))
+
+	for _, tc := range []struct {
+		opcode protocol.Opcode
+		delta  uint64
+		expect uint64
+	}{
+		{protocol.Increment, 5, 10}, // created with the initial value
+		{protocol.Increment, 5, 15},
+		{protocol.Decrement, 3, 12},
+		{protocol.Decrement, 100, 0},
+	} {
+		p := protocol.Packet{Opcode: tc.opcode, Key: []byte("n"), Extras: extras(tc.delta, 10, 0)}
+		if err := roundTrip(conn, &p); assert.NoError(t, err) {
+			assert.Equal(t, tc.expect, binary.BigEndian.Uint64(p.Data))
+		}
+	}
+
+	set := protocol.Packet{Opcode: protocol.Set, Key: []byte("s"), Data: []byte("abc"), Extras: setExtras(0, 0)}
+	if err := roundTrip(conn, &set); assert.NoError(t, err) {
+		p := protocol.Packet{Opcode: protocol.Increment, Key: []byte("s"), Extras: extras(1, 0, 0)}
+		assert.Equal(t, protocol.Status(protocol.StatusIncrDecrOnNonNumericValue), roundTrip(conn, &p))
+	}
+}
+
+func TestServerGetKQPipeline(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+
+	for _, k := range []string{"a", "c"} {
+		p := protocol.Packet{Opcode: protocol.Set, Key: []byte(k), Data: []byte(k), Extras: setExtras(0, 0)}
+		if err := roundTrip(conn, &p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		p := protocol.Packet{Opcode: protocol.GetKQ, Key: []byte(k)}
+		if err := p.Write(conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	noop := protocol.Packet{Opcode: protocol.Noop}
+	if err := noop.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for {
+		var p protocol.Packet
+		if err := p.Read(conn); err != nil {
+			t.Fatal(err)
+		}
+		if p.Opcode == protocol.Noop {
+			break
+		}
+		assert.Equal(t, string(p.Key), string(p.Data))
+		keys = append(keys, string(p.Key))
+	}
+	assert.Equal(t, []string{"a", "c"}, keys)
+}
+
+func TestServerExpiration(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+
+	set := protocol.Packet{Opcode: protocol.Set, Key: []byte("k"), Data: []byte("v"), Extras: setExtras(0, 1)}
+	if err := roundTrip(conn, &set); assert.NoError(t, err) {
+		get := protocol.Packet{Opcode: protocol.Get, Key: []byte("k")}
+		assert.NoError(t, roundTrip(conn, &get))
+		time.Sleep(1100 * time.Millisecond)
+		get = protocol.Packet{Opcode: protocol.Get, Key: []byte("k")}
+		assert.Equal(t, protocol.Status(protocol.StatusKeyNotFound), roundTrip(conn, &get))
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	srv := mctest.NewServer()
+	conn := dial(t, srv)
+	srv.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	p := protocol.Packet{Opcode: protocol.Noop}
+	assert.Error(t, roundTrip(conn, &p))
+}
+
+func TestServerReplaceAppendPrepend(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+
+	replace := protocol.Packet{Opcode: protocol.Replace, Key: []byte("k"), Data: []byte("b"), Extras: setExtras(3, 0)}
+	assert.Equal(t, protocol.Status(protocol.StatusKeyNotFound), roundTrip(conn, &replace))
+	app := protocol.Packet{Opcode: protocol.Append, Key: []byte("k"), Data: []byte("c")}
+	assert.Equal(t, protocol.Status(protocol.StatusItemNotStored), roundTrip(conn, &app))
+
+	set := protocol.Packet{Opcode: protocol.Set, Key: []byte("k"), Data: []byte("x"), Extras: setExtras(0, 0)}
+	if err := roundTrip(conn, &set); err != nil {
+		t.Fatal(err)
+	}
+	replace = protocol.Packet{Opcode: protocol.Replace, Key: []byte("k"), Data: []byte("b"), Extras: setExtras(3, 0)}
+	if err := roundTrip(conn, &replace); assert.NoError(t, err) {
+		app := protocol.Packet{Opcode: protocol.Append, Key: []byte("k"), Data: []byte("c"), CAS: replace.CAS + 1}
+		assert.Equal(t, protocol.Status(protocol.StatusKeyExists), roundTrip(conn, &app))
+		app = protocol.Packet{Opcode: protocol.Append, Key: []byte("k"), Data: []byte("c"), CAS: replace.CAS}
+		assert.NoError(t, roundTrip(conn, &app))
+		pre := protocol.Packet{Opcode: protocol.Prepend, Key: []byte("k"), Data: []byte("a")}
+		assert.NoError(t, roundTrip(conn, &pre))
+
+		get := protocol.Packet{Opcode: protocol.Get, Key: []byte("k")}
+		if err := roundTrip(conn, &get); assert.NoError(t, err) {
+			assert.Equal(t, "abc", string(get.Data))
+			assert.Equal(t, uint32(3), binary.BigEndian.Uint32(get.Extras))
+		}
+	}
+}
+
+func TestServerTouchGAT(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+
+	exp := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		return b
+	}
+	touch := protocol.Packet{Opcode: protocol.Touch, Key: []byte("k"), Extras: exp(10)}
+	assert.Equal(t, protocol.Status(protocol.StatusKeyNotFound), roundTrip(conn, &touch))
+
+	for _, k := range []string{"a", "b"} {
+		set := protocol.Packet{Opcode: protocol.Set, Key: []byte(k), Data: []byte(k), Extras: setExtras(5, 1)}
+		if err := roundTrip(conn, &set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	touch = protocol.Packet{Opcode: protocol.Touch, Key: []byte("a"), Extras: exp(10)}
+	assert.NoError(t, roundTrip(conn, &touch))
+	gat := protocol.Packet{Opcode: protocol.GAT, Key: []byte("b"), Extras: exp(10)}
+	if err := roundTrip(conn, &gat); assert.NoError(t, err) {
+		assert.Equal(t, "b", string(gat.Data))
+		assert.Equal(t, uint32(5), binary.BigEndian.Uint32(gat.Extras))
+	}
+	time.Sleep(1100 * time.Millisecond)
+	for _, k := range []string{"a", "b"} {
+		get := protocol.Packet{Opcode: protocol.Get, Key: []byte(k)}
+		assert.NoError(t, roundTrip(conn, &get))
+	}
+}
+
+func TestServerQuietErrors(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+
+	for n, p := range []protocol.Packet{
+		{Opcode: protocol.SetQ, Key: []byte("a"), Extras: setExtras(0, 0)},
+		{Opcode: protocol.AddQ, Key: []byte("a"), Extras: setExtras(0, 0)},
+		{Opcode: protocol.DeleteQ, Key: []byte("b")},
+		{Opcode: protocol.Noop},
+	} {
+		p.Opaque = uint32(n + 1)
+		if err := p.Write(conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var p protocol.Packet
+	if err := p.Read(conn); assert.Error(t, err) {
+		assert.Equal(t, protocol.Status(protocol.StatusKeyExists), err)
+		assert.Equal(t, uint32(2), p.Opaque)
+	}
+	if err := p.Read(conn); assert.Error(t, err) {
+		assert.Equal(t, protocol.Status(protocol.StatusKeyNotFound), err)
+		assert.Equal(t, uint32(3), p.Opaque)
+	}
+	if err := p.Read(conn); assert.NoError(t, err) {
+		assert.Equal(t, protocol.Opcode(protocol.Noop), p.Opcode)
+	}
+}
+
+func TestServerFailNext(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn := dial(t, srv)
+
+	srv.FailNext(protocol.StatusBusy, 2)
+	for range 2 {
+		get := protocol.Packet{Opcode: protocol.Get, Key: []byte("k")}
+		assert.Equal(t, protocol.Status(protocol.StatusBusy), roundTrip(conn, &get))
+		noop := protocol.Packet{Opcode: protocol.Noop}
+		assert.NoError(t, roundTrip(conn, &noop))
+	}
+	get := protocol.Packet{Opcode: protocol.Get, Key: []byte("k")}
+	assert.Equal(t, protocol.Status(protocol.StatusKeyNotFound), roundTrip(conn, &get))
+}
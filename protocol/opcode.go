@@ -9,12 +9,22 @@ const (
 	Get       Opcode = 0x00
 	Set              = 0x01
 	Add              = 0x02
+	Replace          = 0x03
 	Delete           = 0x04
 	Increment        = 0x05
 	Decrement        = 0x06
 	Noop             = 0x0a
 	Version          = 0x0b
 	GetKQ            = 0x0d
+	Append           = 0x0e
+	Prepend          = 0x0f
+	SetQ             = 0x11
+	AddQ             = 0x12
+	DeleteQ          = 0x14
+	Touch            = 0x1c
+	GAT              = 0x1d
+	GATQ             = 0x1e
+	GATKQ            = 0x24
 )
 
 func (c Opcode) String() string {
@@ -25,6 +35,12 @@ func (c Opcode) String() string {
 		return "set"
 	case Add:
 		return "add"
+	case Replace:
+		return "replace"
+	case Append:
+		return "append"
+	case Prepend:
+		return "prepend"
 	case Noop:
 		return "noop"
 	case GetKQ:
@@ -37,6 +53,20 @@ func (c Opcode) String() string {
 		return "increment"
 	case Decrement:
 		return "decrement"
+	case SetQ:
+		return "set_q"
+	case AddQ:
+		return "add_q"
+	case DeleteQ:
+		return "delete_q"
+	case Touch:
+		return "touch"
+	case GAT:
+		return "gat"
+	case GATQ:
+		return "gatq"
+	case GATKQ:
+		return "gat_kq"
 	}
 	return fmt.Sprintf("undefined Opcode: %d", c)
 }
@@ -1,9 +1,11 @@
 package protocol
 
 import (
+	"bufio"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"slices"
 )
 
 const (
@@ -54,8 +56,24 @@ Field        (offset) (value)
 	Value               :
 */
 func (p *Packet) Write(w io.Writer) error {
-	total := len(p.Extras) + len(p.Key) + len(p.Data)
-	if need := 24 + total; cap(p.scratch) < need {
+	if _, err := w.Write(p.Header(len(p.Data))); err != nil {
+		return err
+	}
+	if len(p.Data) == 0 {
+		return nil
+	}
+	if _, err := w.Write(p.Data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Header returns the header of the request with a value of valueLen bytes followed
+// by extras and key, the value is to be written right after it instead of Data.
+// The header is valid until the next call.
+func (p *Packet) Header(valueLen int) []byte {
+	total := len(p.Extras) + len(p.Key) + valueLen
+	if need := 24 + len(p.Extras) + len(p.Key); cap(p.scratch) < need {
 		p.scratch = make([]byte, 0, need)
 	}
 	p.scratch = p.scratch[0:24]
@@ -75,12 +93,8 @@ func (p *Packet) Write(w io.Writer) error {
 	{
 		p.scratch = append(p.scratch, p.Extras...)
 		p.scratch = append(p.scratch, p.Key...)
-		p.scratch = append(p.scratch, p.Data...)
 	}
-	if _, err := w.Write(p.scratch); err != nil {
-		return err
-	}
-	return nil
+	return p.scratch
 }
 
 /*
@@ -100,53 +114,108 @@ Field        (offset) (value)
 	Value               :
 */
 func (p *Packet) Read(r io.Reader) error {
+	extras, keyLen, totalLen, err := p.readHeader(r)
+	if err != nil {
+		return err
+	}
+	payload := make([]byte, totalLen)
+	switch n, err := io.ReadFull(r, payload); {
+	case err != nil:
+		return err
+	case n != totalLen:
+		return io.ErrUnexpectedEOF
+	}
+	{
+		p.Extras = payload[0:extras]
+		p.Key = payload[extras : extras+keyLen]
+		p.Data = payload[extras+keyLen : totalLen]
+	}
+	return nil
+}
+
+// ReadInto reads the response like Read, but appends the value to dst instead of
+// allocating the payload: Data is dst extended by the value. Extras and Key are
+// valid until the next call.
+func (p *Packet) ReadInto(r io.Reader, dst []byte) error {
+	valueLen, err := p.ReadHead(r)
+	if err != nil {
+		return err
+	}
+	n := len(dst)
+	dst = slices.Grow(dst, valueLen)[:n+valueLen]
+	if _, err := io.ReadFull(r, dst[n:]); err != nil {
+		return err
+	}
+	p.Data = dst
+	return nil
+}
+
+// ReadHead reads the response up to the value, which is left in r: the caller must
+// read valueLen bytes before the next response. Extras and Key are valid until the
+// next call.
+func (p *Packet) ReadHead(r io.Reader) (valueLen int, err error) {
+	extras, keyLen, totalLen, err := p.readHeader(r)
+	if err != nil {
+		return 0, err
+	}
+	if need := extras + keyLen; cap(p.scratch) < need {
+		p.scratch = make([]byte, 0, need)
+	}
+	head := p.scratch[:extras+keyLen]
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, err
+	}
+	{
+		p.Extras = head[:extras]
+		p.Key = head[extras:]
+		p.Data = p.Data[:0]
+	}
+	return totalLen - len(head), nil
+}
+
+// readHeader reads the response header, the body of an error response is skipped.
+func (p *Packet) readHeader(r io.Reader) (extras, keyLen, totalLen int, err error) {
 	if need := 24; cap(p.scratch) < need {
 		p.scratch = make([]byte, 0, need)
 	}
 	data := p.scratch[0:24]
 	switch n, err := io.ReadFull(r, data); {
 	case err != nil:
-		return err
+		return 0, 0, 0, err
 	case n != 24:
-		return io.ErrUnexpectedEOF
+		return 0, 0, 0, io.ErrUnexpectedEOF
 	}
 
 	if data[0] != MagicResp {
-		return fmt.Errorf("memcache: bad magic number in response")
+		return 0, 0, 0, fmt.Errorf("memcache: bad magic number in response")
 	}
 
 	p.Opcode = Opcode(data[1])
 
-	keyLen := int(endian.Uint16(data[2:4]))
-	extras := int(data[4])
+	keyLen = int(endian.Uint16(data[2:4]))
+	extras = int(data[4])
 
 	if data[5] != DataTypeRawBytes {
-		return fmt.Errorf("memcache: invalid data type")
+		return 0, 0, 0, fmt.Errorf("memcache: invalid data type")
 	}
 
-	totalLen := int(endian.Uint32(data[8:12]))
-
-	if status := Status(endian.Uint16(data[6:8])); status != StatusOK {
-		io.CopyN(io.Discard, r, int64(totalLen))
-		return status
+	totalLen = int(endian.Uint32(data[8:12]))
+	if extras+keyLen > totalLen {
+		return 0, 0, 0, fmt.Errorf("memcache: invalid body length")
 	}
 
 	p.Opaque = endian.Uint32(data[12:16])
 	p.CAS = endian.Uint64(data[16:24])
 
-	payload := make([]byte, totalLen)
-	switch n, err := io.ReadFull(r, payload); {
-	case err != nil:
-		return err
-	case n != totalLen:
-		return io.ErrUnexpectedEOF
-	}
-	{
-		p.Extras = payload[0:extras]
-		p.Key = payload[extras : extras+keyLen]
-		p.Data = payload[extras+keyLen : totalLen]
+	if status := Status(endian.Uint16(data[6:8])); status != StatusOK {
+		if br, ok := r.(*bufio.Reader); ok {
+			br.Discard(totalLen)
+		} else {
+			io.CopyN(io.Discard, r, int64(totalLen))
+		}
+		return 0, 0, 0, status
 	}
-	return nil
+	return extras, keyLen, totalLen, nil
 }
 
 func (p Packet) String() string {
@@ -1,39 +1,51 @@
-package protocol
+package protocol_test
 
 import (
 	"encoding/binary"
 	"fmt"
 	"net"
 	"testing"
+
+	"github.com/kinescope/mc/mctest"
+	"github.com/kinescope/mc/protocol"
 )
 
 func TestSetGet(t *testing.T) {
-	conn, err := net.Dial("tcp", "127.0.0.1:11211")
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn, err := net.Dial("tcp", srv.Addr())
 	if err != nil {
 		t.Fatal(err)
 	}
 	extras := make([]byte, 8)
 	binary.BigEndian.PutUint32(extras[0:4], 22) //uint32 flags
 	binary.BigEndian.PutUint32(extras[4:8], 3600)
-	h := Packet{
+	h := protocol.Packet{
 		Key:    []byte("test"),
 		Data:   []byte("data"),
-		Opcode: Set,
+		Opcode: protocol.Set,
 		CAS:    0,
 		Extras: extras,
 	}
 
 	h.Write(conn)
 
-	h.Read(conn)
+	if err := h.Read(conn); err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println(h)
 
-	h2 := Packet{
+	h2 := protocol.Packet{
 		Key:    []byte("test"),
-		Opcode: Get,
+		Opcode: protocol.Get,
 	}
 	h2.Write(conn)
-	h2.Read(conn)
+	if err := h2.Read(conn); err != nil {
+		t.Fatal(err)
+	}
+	if string(h2.Data) != "data" || binary.BigEndian.Uint32(h2.Extras) != 22 {
+		t.Fatalf("unexpected response: %s %v", h2.Data, h2.Extras)
+	}
 
 	fmt.Println(h2, string(h2.Data), h2.Extras)
 	conn.Close()
@@ -41,20 +53,69 @@ func TestSetGet(t *testing.T) {
 }
 
 func TestVersion(t *testing.T) {
-	conn, err := net.Dial("tcp", "127.0.0.1:11211")
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn, err := net.Dial("tcp", srv.Addr())
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	h := Packet{
-		Opcode: Version,
+	h := protocol.Packet{
+		Opcode: protocol.Version,
 		CAS:    0,
 	}
 
 	h.Write(conn)
 
-	h.Read(conn)
+	if err := h.Read(conn); err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println(h, "version: ", string(h.Data))
 
 	conn.Close()
 }
+
+func TestReadInto(t *testing.T) {
+	srv := mctest.NewServer()
+	defer srv.Close()
+	conn, err := net.Dial("tcp", srv.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	extras := make([]byte, 8)
+	binary.BigEndian.PutUint32(extras[0:4], 22)
+	h := protocol.Packet{
+		Key:    []byte("test"),
+		Data:   []byte("data"),
+		Opcode: protocol.Set,
+		Extras: extras,
+	}
+	h.Write(conn)
+	if err := h.Read(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	h = protocol.Packet{
+		Key:    []byte("test"),
+		Opcode: protocol.Get,
+	}
+	h.Write(conn)
+	dst := []byte("prefix:")
+	if err := h.ReadInto(conn, dst); err != nil {
+		t.Fatal(err)
+	}
+	if string(h.Data) != "prefix:data" || binary.BigEndian.Uint32(h.Extras) != 22 {
+		t.Fatalf("unexpected response: %s %v", h.Data, h.Extras)
+	}
+
+	h = protocol.Packet{
+		Key:    []byte("missing"),
+		Opcode: protocol.Get,
+	}
+	h.Write(conn)
+	if err := h.ReadInto(conn, nil); err != protocol.Status(protocol.StatusKeyNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}